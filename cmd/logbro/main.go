@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"flag"
 	"fmt"
@@ -9,12 +8,19 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/lch88/logbro/internal/buffer"
+	"github.com/lch88/logbro/internal/input"
 	"github.com/lch88/logbro/internal/parser"
+	"github.com/lch88/logbro/internal/redact"
 	"github.com/lch88/logbro/internal/server"
+	"github.com/lch88/logbro/internal/store"
 )
 
 var (
@@ -25,11 +31,45 @@ var (
 
 func main() {
 	port := flag.Int("port", 8080, "HTTP server port")
-	bufSize := flag.Int("buffer", 10000, "Max log lines to buffer")
+	bufSize := flag.Int("buffer", 10000, "Max log lines to buffer, 0 for no line limit (needs -buffer-bytes)")
+	storeDir := flag.String("store", "", "Directory to keep logs evicted from the buffer in, queryable via /api/logs?afterId=")
+	storeAll := flag.Bool("store-all", false, "Write every log to -store as it arrives, not only evicted ones")
+	storeMaxBytes := flag.String("store-max-bytes", "1GiB", "Disk space for -store before the oldest segments are deleted, 0 for no limit")
+	storeMaxAge := flag.Duration("store-max-age", 0, "Delete -store segments older than this, e.g. 168h (default: keep)")
+	bufBytes := flag.String("buffer-bytes", "", "Max estimated memory for buffered logs, e.g. 256MB or 1GiB; oldest logs are evicted first")
 	noOpen := flag.Bool("no-open", false, "Don't auto-open browser")
 	devMode := flag.Bool("dev", false, "Development mode (API only, no static files)")
 	version := flag.Bool("version", false, "Show version")
+	exitWithChild := flag.Bool("exit-code", false, "Exit with the wrapped command's exit code once it finishes")
+	syslogAddrs := flag.String("syslog", "", "Listen for syslog messages, e.g. udp://:5514,tcp://:5514")
+	gelfAddrs := flag.String("gelf", "", "Listen for GELF messages, e.g. udp://:12201,tcp://:12201")
+	forwardAddrs := flag.String("forward", "", "Listen for Fluent Forward protocol events, e.g. tcp://:24224")
+	lokiSourceLabels := flag.String("loki-source-labels", strings.Join(server.DefaultLokiSourceLabels, ","), "Loki stream labels used as the log source, first match wins")
+	multiline := flag.Bool("multiline", false, "Group stack traces and continuation lines into one entry")
+	multilineTimeout := flag.Duration("multiline-timeout", input.DefaultMultilineTimeout, "How long to wait for more lines of a multiline entry")
+	var multilineStarts stringList
+	flag.Var(&multilineStarts, "multiline-start", "Regex matching the first line of an entry, replacing the built-in rules (repeatable)")
+	patternFile := flag.String("patterns", "", "JSON file of named grok/regex patterns for in-house log formats")
+	levelScheme := flag.String("level-scheme", parser.LevelSchemeAuto, "How numeric levels are read: "+strings.Join(parser.LevelSchemes, ", "))
+	var levels stringList
+	flag.Var(&levels, "level", "Define a level as NAME=PRIORITY (TRACE 10 ... FATAL 60) or map a name onto one as NAME=LEVEL (repeatable)")
+	timeZone := flag.String("tz", "UTC", "Time zone for timestamps without one, e.g. Local or Europe/Berlin")
+	var timeLayouts stringList
+	flag.Var(&timeLayouts, "time-layout", "Go time layout to find timestamps with, or SOURCE=LAYOUT for one source (repeatable)")
+	var formats stringList
+	flag.Var(&formats, "format", "Log format to use instead of auto-detection, or SOURCE=FORMAT for one source such as a Compose service (repeatable)")
+	redactOn := flag.Bool("redact", false, "Mask secrets and personal data (tokens, passwords, AWS keys, emails, card numbers) before storing logs")
+	redactAction := flag.String("redact-action", string(redact.ActionMask), "What to do with redacted values: mask, hash or drop")
+	redactFields := flag.String("redact-fields", redact.DefaultFieldPattern.String(), "Regex of field names whose values are always redacted")
+	var redactPatterns stringList
+	flag.Var(&redactPatterns, "redact-pattern", "Extra regex to redact, optionally NAME=REGEX; a (?P<secret>...) group limits what is replaced (repeatable, implies -redact)")
+	var files stringList
+	flag.Var(&files, "file", "Tail a file or glob pattern like tail -F (repeatable)")
 
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: logbro [flags] [-- command [args...]]\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	if *version {
@@ -39,18 +79,158 @@ func main() {
 		return
 	}
 
+	mlConfig := input.MultilineConfig{
+		Enabled: *multiline,
+		Timeout: *multilineTimeout,
+	}
+	for _, pattern := range multilineStarts {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Fatalf("Invalid -multiline-start pattern %q: %v", pattern, err)
+		}
+		mlConfig.StartPatterns = append(mlConfig.StartPatterns, re)
+	}
+
+	if err := defineLevels(levels); err != nil {
+		log.Fatalf("Invalid -level: %v", err)
+	}
+
 	// Initialize components
-	ringBuf := buffer.New(*bufSize)
+	var bufOpts []buffer.Option
+	if *bufBytes != "" {
+		n, err := parseByteSize(*bufBytes)
+		if err != nil {
+			log.Fatalf("Invalid -buffer-bytes: %v", err)
+		}
+		bufOpts = append(bufOpts, buffer.WithMaxBytes(n))
+	} else if *bufSize <= 0 {
+		log.Fatalf("Invalid -buffer %d: must be positive unless -buffer-bytes is set", *bufSize)
+	}
+	if *storeDir != "" {
+		var maxBytes int64
+		if *storeMaxBytes != "0" {
+			n, err := parseByteSize(*storeMaxBytes)
+			if err != nil {
+				log.Fatalf("Invalid -store-max-bytes: %v", err)
+			}
+			maxBytes = n
+		}
+		st, err := store.Open(*storeDir, store.WithMaxBytes(maxBytes), store.WithMaxAge(*storeMaxAge))
+		if err != nil {
+			log.Fatalf("Failed to open -store: %v", err)
+		}
+		bufOpts = append(bufOpts, buffer.WithStore(st))
+		if *storeAll {
+			bufOpts = append(bufOpts, buffer.WithWriteThrough())
+		}
+	}
+	ringBuf := buffer.New(max(*bufSize, 0), bufOpts...)
 	logParser := parser.New()
+	if err := logParser.SetLevelScheme(*levelScheme); err != nil {
+		log.Fatalf("Invalid -level-scheme: %v", err)
+	}
+	loc, err := time.LoadLocation(*timeZone)
+	if err != nil {
+		log.Fatalf("Invalid -tz: %v", err)
+	}
+	logParser.SetLocation(loc)
+	for _, l := range timeLayouts {
+		var err error
+		if source, layout, ok := strings.Cut(l, "="); ok {
+			err = logParser.SetSourceTimeLayout(source, layout)
+		} else {
+			err = logParser.SetTimeLayout(l)
+		}
+		if err != nil {
+			log.Fatalf("Invalid -time-layout %q: %v", l, err)
+		}
+	}
+	if *patternFile != "" {
+		if err := logParser.LoadPatterns(*patternFile); err != nil {
+			log.Fatalf("Invalid -patterns file: %v", err)
+		}
+	}
+	for _, f := range formats {
+		var err error
+		if source, name, ok := strings.Cut(f, "="); ok {
+			err = logParser.SetSourceFormat(source, name)
+		} else {
+			err = logParser.SetFormat(f)
+		}
+		if err != nil {
+			log.Fatalf("Invalid -format %q: %v", f, err)
+		}
+	}
 
-	var opts []server.Option
+	opts := []server.Option{
+		server.WithParser(logParser),
+		server.WithLokiSourceLabels(strings.Split(*lokiSourceLabels, ",")),
+		server.WithMultiline(mlConfig),
+	}
+	if *redactOn || len(redactPatterns) > 0 {
+		r, err := newRedactor(*redactAction, *redactFields, redactPatterns)
+		if err != nil {
+			log.Fatalf("Invalid redaction settings: %v", err)
+		}
+		opts = append(opts, server.WithRedactor(r))
+	}
 	if *devMode {
 		opts = append(opts, server.WithDevMode())
 	}
+	args := flag.Args()
+	if len(args) > 0 {
+		opts = append(opts, server.WithCommand(strings.Join(args, " ")))
+	}
 	srv := server.New(ringBuf, *port, opts...)
 
-	// Start stdin reader
-	go readStdin(ringBuf, logParser, srv.Hub())
+	// Only read stdin alongside other inputs when something is piped in
+	hasOtherInputs := len(files) > 0 || *syslogAddrs != "" || *gelfAddrs != "" || *forwardAddrs != ""
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	// Start file tailing
+	if len(files) > 0 {
+		go input.NewTailer(files, logParser, mlConfig, srv.Ingest).Run(ctx)
+	}
+
+	// Start syslog listeners
+	if *syslogAddrs != "" {
+		if err := input.StartSyslog(ctx, *syslogAddrs, logParser, srv.Ingest); err != nil {
+			log.Fatalf("Syslog error: %v", err)
+		}
+	}
+
+	// Start GELF listeners
+	if *gelfAddrs != "" {
+		if err := input.StartGELF(ctx, *gelfAddrs, logParser, srv.Ingest); err != nil {
+			log.Fatalf("GELF error: %v", err)
+		}
+	}
+
+	// Start Fluent Forward listeners
+	if *forwardAddrs != "" {
+		if err := input.StartForward(ctx, *forwardAddrs, logParser, srv.Ingest); err != nil {
+			log.Fatalf("Forward error: %v", err)
+		}
+	}
+
+	// Catch signals before starting the command, which doesn't get the
+	// terminal's Ctrl-C itself
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	// Start the wrapped command, or fall back to reading stdin
+	var child *input.Command
+	if len(args) > 0 {
+		var err error
+		child, err = input.StartCommand(args[0], args[1:], logParser, mlConfig, srv.Ingest)
+		if err != nil {
+			log.Fatalf("Command error: %v", err)
+		}
+	} else if !hasOtherInputs || stdinIsPipe() {
+		go readStdin(logParser, mlConfig, srv)
+	}
 
 	// Open browser (skip in dev mode - use Vite's port instead)
 	if !*noOpen && !*devMode {
@@ -67,41 +247,198 @@ func main() {
 	}()
 
 	// Wait for interrupt signal
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	exitCode := 0
+	if child != nil {
+		exitCode = waitChild(child, srv, quit, *exitWithChild)
+	} else {
+		<-quit
+	}
 
 	log.Println("Shutting down...")
+	stop()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*1000000000) // 5 seconds
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("Shutdown error: %v", err)
 	}
+	if err := ringBuf.Close(); err != nil {
+		log.Printf("Store error: %v", err)
+	}
+
+	if exitCode != 0 {
+		cancel()
+		os.Exit(exitCode)
+	}
 }
 
-func readStdin(buf *buffer.Ring, p *parser.Parser, hub *server.Hub) {
-	scanner := bufio.NewScanner(os.Stdin)
-	// Increase buffer size for long log lines
-	const maxScanTokenSize = 1024 * 1024 // 1MB
-	scanBuf := make([]byte, maxScanTokenSize)
-	scanner.Buffer(scanBuf, maxScanTokenSize)
+// commandKillTimeout is how long the wrapped command gets to exit after a
+// forwarded signal before it is killed
+const commandKillTimeout = 10 * time.Second
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		entry := p.Parse(line)
-		entry = buf.Add(entry)
-		hub.Broadcast(entry)
+// waitChild forwards signals to the wrapped command while it runs and returns
+// the code logbro should exit with once it is time to shut down. A command
+// still running after commandKillTimeout or a second signal is killed.
+func waitChild(child *input.Command, srv *server.Server, quit <-chan os.Signal, exitWithChild bool) int {
+	done := child.Done()
+	var deadline <-chan time.Time
+	for {
+		select {
+		case sig := <-quit:
+			if done == nil {
+				// Child already exited, this signal is for us
+				return 0
+			}
+			if deadline != nil {
+				log.Printf("Killing command")
+				killChild(child)
+				continue
+			}
+			if err := child.Signal(sig); err != nil {
+				log.Printf("Failed to forward %v to command: %v", sig, err)
+			}
+			deadline = time.After(commandKillTimeout)
+
+		case <-deadline:
+			log.Printf("Command still running %v after the signal, killing it", commandKillTimeout)
+			killChild(child)
+
+		case <-done:
+			deadline = nil
+			code := child.ExitCode()
+			if err := child.Err(); err != nil {
+				log.Printf("Command error: %v", err)
+			}
+			log.Printf("Command exited with code %d", code)
+			srv.Hub().SetExited(code)
+			if exitWithChild {
+				return code
+			}
+			done = nil
+		}
 	}
+}
 
-	if err := scanner.Err(); err != nil {
+func killChild(child *input.Command) {
+	if err := child.Kill(); err != nil {
+		log.Printf("Failed to kill command: %v", err)
+	}
+}
+
+func readStdin(p *parser.Parser, ml input.MultilineConfig, srv *server.Server) {
+	asm := input.NewAssembler(ml, input.LineEmitter(p, srv.Ingest))
+	err := input.ReadLines(os.Stdin, asm.Add)
+	asm.Flush()
+	if err != nil {
 		log.Printf("Stdin read error: %v", err)
 	}
 
-	hub.SetStdinClosed()
+	srv.Hub().SetStdinClosed()
 	log.Println("Stdin closed")
 }
 
+// defineLevels applies -level flags; definitions go first so aliases can
+// refer to them regardless of flag order
+func defineLevels(specs []string) error {
+	var aliases [][2]string
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			return fmt.Errorf("%q is not NAME=PRIORITY or NAME=LEVEL", spec)
+		}
+		if priority, err := strconv.Atoi(value); err == nil {
+			if err := parser.DefineLevel(name, priority); err != nil {
+				return err
+			}
+		} else {
+			aliases = append(aliases, [2]string{name, value})
+		}
+	}
+	for _, a := range aliases {
+		if err := parser.AliasLevel(a[0], a[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseByteSize parses a size such as 1048576, 512KB, 256MB or 1GiB;
+// decimal and binary units both count in powers of 1024
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := len(s)
+	for i > 0 && (s[i-1] < '0' || s[i-1] > '9') {
+		i--
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	unit := strings.ToUpper(strings.TrimSpace(s[i:]))
+	unit = strings.TrimSuffix(strings.TrimSuffix(unit, "B"), "I")
+	shift := 0
+	if unit != "" {
+		shift = strings.Index("KMGT", unit) + 1
+		if shift == 0 || len(unit) != 1 {
+			return 0, fmt.Errorf("invalid size unit in %q", s)
+		}
+	}
+	return int64(n * float64(int64(1)<<(10*shift))), nil
+}
+
+// newRedactor builds the redaction stage from the -redact-* flags
+func newRedactor(action, fields string, patterns []string) (*redact.Redactor, error) {
+	a, err := redact.ParseAction(action)
+	if err != nil {
+		return nil, err
+	}
+	opts := []redact.Option{redact.WithAction(a)}
+
+	var fieldPattern *regexp.Regexp
+	if fields != "" {
+		if fieldPattern, err = regexp.Compile(fields); err != nil {
+			return nil, fmt.Errorf("-redact-fields: %w", err)
+		}
+	}
+	opts = append(opts, redact.WithFieldPattern(fieldPattern))
+
+	for i, p := range patterns {
+		name := fmt.Sprintf("pattern%d", i+1)
+		// NAME=REGEX, unless the '=' belongs to the regex itself
+		if n, expr, ok := strings.Cut(p, "="); ok && regexp.MustCompile(`^\w+$`).MatchString(n) {
+			name, p = n, expr
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("-redact-pattern %q: %w", p, err)
+		}
+		opts = append(opts, redact.WithPattern(name, re))
+	}
+	return redact.New(opts...), nil
+}
+
+// stdinIsPipe reports whether stdin is redirected rather than a terminal
+func stdinIsPipe() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func openBrowser(url string) {
 	var cmd string
 	var args []string
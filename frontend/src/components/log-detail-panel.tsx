@@ -3,6 +3,7 @@ import type { LogEntry } from '@/lib/api'
 import { Button } from '@/components/ui/button'
 import { X, Copy, Check } from 'lucide-react'
 import { cn } from '@/lib/utils'
+import { spanLink, spanStyle, styledSegments } from '@/lib/ansi'
 import { getSourceColorClass } from './source-tabs'
 
 function copyToClipboard(text: string) {
@@ -15,6 +16,7 @@ interface LogDetailPanelProps {
 }
 
 const levelColors: Record<string, string> = {
+  TRACE: 'text-gray-600',
   DEBUG: 'text-gray-500',
   INFO: 'text-blue-400',
   WARN: 'text-yellow-400',
@@ -108,6 +110,29 @@ export function LogDetailPanel({ entry, onClose }: LogDetailPanelProps) {
               </span>
             </div>
           )}
+          {entry.parsed?.traceId && (
+            <div className="flex items-center gap-2">
+              <span className="text-muted-foreground w-20">Trace ID:</span>
+              <span className="font-mono break-all">{entry.parsed.traceId}</span>
+            </div>
+          )}
+          {entry.parsed?.spanId && (
+            <div className="flex items-center gap-2">
+              <span className="text-muted-foreground w-20">Span ID:</span>
+              <span className="font-mono break-all">
+                {entry.parsed.spanId}
+                {entry.parsed.parentSpanId && (
+                  <span className="text-muted-foreground"> ← {entry.parsed.parentSpanId}</span>
+                )}
+              </span>
+            </div>
+          )}
+          {entry.parsed?.requestId && (
+            <div className="flex items-center gap-2">
+              <span className="text-muted-foreground w-20">Request ID:</span>
+              <span className="font-mono break-all">{entry.parsed.requestId}</span>
+            </div>
+          )}
         </div>
 
         {/* Message */}
@@ -173,7 +198,24 @@ export function LogDetailPanel({ entry, onClose }: LogDetailPanelProps) {
             </Button>
           </div>
           <pre className="font-mono text-xs bg-muted/50 p-3 rounded-md whitespace-pre-wrap break-all overflow-auto max-h-[300px]">
-            {entry.raw}
+            {styledSegments(entry, entry.raw).map((segment, i) =>
+              spanLink(segment.span) ? (
+                <a
+                  key={i}
+                  href={spanLink(segment.span)}
+                  target="_blank"
+                  rel="noreferrer"
+                  className="underline"
+                  style={spanStyle(segment.span!)}
+                >
+                  {segment.text}
+                </a>
+              ) : (
+                <span key={i} style={segment.span && spanStyle(segment.span)}>
+                  {segment.text}
+                </span>
+              )
+            )}
           </pre>
         </div>
       </div>
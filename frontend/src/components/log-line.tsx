@@ -1,6 +1,7 @@
 import { memo, useCallback } from 'react'
 import { cn } from '@/lib/utils'
 import type { LogEntry } from '@/lib/api'
+import { spanStyle, styledSegments } from '@/lib/ansi'
 import type { ColumnVisibility } from '@/hooks/use-settings'
 import { getSourceColorClass } from './source-tabs'
 
@@ -16,6 +17,7 @@ interface LogLineProps {
 }
 
 const levelColors: Record<string, string> = {
+  TRACE: 'text-gray-600',
   DEBUG: 'text-gray-500',
   INFO: 'text-blue-400',
   WARN: 'text-yellow-400',
@@ -24,6 +26,7 @@ const levelColors: Record<string, string> = {
 }
 
 const levelBgColors: Record<string, string> = {
+  TRACE: 'bg-gray-500/5',
   DEBUG: 'bg-gray-500/10',
   INFO: 'bg-blue-500/10',
   WARN: 'bg-yellow-500/10',
@@ -104,6 +107,20 @@ export const LogLine = memo(function LogLine({
     }
   }
 
+  const renderMessage = () => {
+    const segments = styledSegments(entry, message)
+    if (segments.length === 1 && !segments[0].span) return highlightSearch(message)
+    return segments.map((segment, i) =>
+      segment.span ? (
+        <span key={i} style={spanStyle(segment.span)}>
+          {highlightSearch(segment.text)}
+        </span>
+      ) : (
+        <span key={i}>{highlightSearch(segment.text)}</span>
+      )
+    )
+  }
+
   // Unified layout - all messages in single column with fixed-width metadata columns
   return (
     <div
@@ -164,7 +181,7 @@ export const LogLine = memo(function LogLine({
           WebkitBoxOrient: 'vertical',
         }}
       >
-        {highlightSearch(message)}
+        {renderMessage()}
       </span>
 
       {/* Fields */}
@@ -24,11 +24,13 @@ interface LogToolbarProps {
   onScrollToBottom: () => void
   settings: Settings
   onSettingChange: <K extends keyof Settings>(key: K, value: Settings[K]) => void
+  logLevels?: string[]
 }
 
-const LOG_LEVELS = ['DEBUG', 'INFO', 'WARN', 'ERROR', 'FATAL'] as const
+const LOG_LEVELS = ['TRACE', 'DEBUG', 'INFO', 'WARN', 'ERROR', 'FATAL']
 
 const levelColors: Record<string, string> = {
+  TRACE: 'data-[state=on]:bg-gray-700',
   DEBUG: 'data-[state=on]:bg-gray-600',
   INFO: 'data-[state=on]:bg-blue-600',
   WARN: 'data-[state=on]:bg-yellow-600',
@@ -45,6 +47,7 @@ export function LogToolbar({
   onScrollToBottom,
   settings,
   onSettingChange,
+  logLevels = LOG_LEVELS,
 }: LogToolbarProps) {
   const [search, setSearch] = useState(filter.search || '')
   const [regex, setRegex] = useState(filter.regex || false)
@@ -112,7 +115,7 @@ export function LogToolbar({
 
       {/* Level filters */}
       <div className="flex items-center gap-1 ml-2">
-        {LOG_LEVELS.map((level) => (
+        {logLevels.map((level) => (
           <Toggle
             key={level}
             pressed={levels.includes(level)}
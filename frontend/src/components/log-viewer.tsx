@@ -20,6 +20,8 @@ export function LogViewer() {
     filter,
     paused,
     stdinOpen,
+    exitCode,
+    levels,
     connected,
     loading,
     setPaused,
@@ -125,6 +127,7 @@ export function LogViewer() {
         onScrollToBottom={handleScrollToBottom}
         settings={settings}
         onSettingChange={updateSetting}
+        logLevels={levels}
       />
 
       {sources.length > 1 && (
@@ -178,6 +181,7 @@ export function LogViewer() {
       <StatusBar
         connected={connected}
         stdinOpen={stdinOpen}
+        exitCode={exitCode}
         logCount={logs.length}
         paused={paused}
       />
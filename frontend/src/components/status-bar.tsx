@@ -3,11 +3,12 @@ import { cn } from '@/lib/utils'
 interface StatusBarProps {
   connected: boolean
   stdinOpen: boolean
+  exitCode?: number
   logCount: number
   paused: boolean
 }
 
-export function StatusBar({ connected, stdinOpen, logCount, paused }: StatusBarProps) {
+export function StatusBar({ connected, stdinOpen, exitCode, logCount, paused }: StatusBarProps) {
   return (
     <div className="flex items-center gap-4 px-3 py-1.5 border-t bg-muted/30 text-xs text-muted-foreground">
       {/* Connection status */}
@@ -29,7 +30,13 @@ export function StatusBar({ connected, stdinOpen, logCount, paused }: StatusBarP
             stdinOpen ? 'bg-blue-500 animate-pulse' : 'bg-gray-400'
           )}
         />
-        <span>{stdinOpen ? 'Streaming' : 'Stream ended'}</span>
+        <span>
+          {stdinOpen
+            ? 'Streaming'
+            : exitCode !== undefined
+              ? `Exited with code ${exitCode}`
+              : 'Stream ended'}
+        </span>
       </div>
 
       {/* Log count */}
@@ -1,7 +1,8 @@
 import { useCallback, useEffect, useMemo, useRef, useState } from 'react'
 import type { LogEntry, LogFilter } from '@/lib/api'
-import { fetchLogs, enrichLogEntry } from '@/lib/api'
+import { fetchLogs, fetchStatus, enrichLogEntry } from '@/lib/api'
 import { useWebSocket } from './use-websocket'
+import type { InputStatus } from './use-websocket'
 
 const MAX_LOGS = 10000
 
@@ -10,6 +11,8 @@ export function useLogs() {
   const [filter, setFilter] = useState<LogFilter>({})
   const [paused, setPaused] = useState(false)
   const [stdinOpen, setStdinOpen] = useState(true)
+  const [exitCode, setExitCode] = useState<number>()
+  const [levels, setLevels] = useState<string[]>()
   const [loading, setLoading] = useState(true)
   const pausedRef = useRef(paused)
   pausedRef.current = paused
@@ -33,9 +36,14 @@ export function useLogs() {
     })
   }, [])
 
+  const handleStatusChange = useCallback((status: InputStatus) => {
+    setStdinOpen(status.stdinOpen)
+    setExitCode(status.exitCode)
+  }, [])
+
   const { connected, updateFilter } = useWebSocket({
     onLog: handleNewLog,
-    onStatusChange: setStdinOpen,
+    onStatusChange: handleStatusChange,
     filter,
   })
 
@@ -107,6 +115,15 @@ export function useLogs() {
     loadInitialLogs()
   }, []) // eslint-disable-line react-hooks/exhaustive-deps
 
+  // Levels, including user-defined ones, in severity order
+  useEffect(() => {
+    fetchStatus()
+      .then((status) => setLevels(status.levels.map((l) => l.name)))
+      .catch((e) => {
+        console.error('Failed to load levels:', e)
+      })
+  }, [])
+
   return {
     logs,
     allLogs,
@@ -114,6 +131,8 @@ export function useLogs() {
     filter,
     paused,
     stdinOpen,
+    exitCode,
+    levels,
     connected,
     loading,
     setPaused,
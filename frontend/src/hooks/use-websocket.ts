@@ -2,13 +2,18 @@ import { useCallback, useEffect, useRef, useState } from 'react'
 import type { LogEntry, LogFilter } from '@/lib/api'
 
 interface WSMessage {
-  type: 'log' | 'status' | 'pong'
-  data?: LogEntry | { stdinOpen: boolean }
+  type: 'log' | 'status' | 'pong' | 'error'
+  data?: LogEntry | InputStatus | { error: string }
+}
+
+export interface InputStatus {
+  stdinOpen: boolean
+  exitCode?: number
 }
 
 interface UseWebSocketOptions {
   onLog: (entry: LogEntry) => void
-  onStatusChange?: (stdinOpen: boolean) => void
+  onStatusChange?: (status: InputStatus) => void
   filter?: LogFilter
 }
 
@@ -45,8 +50,10 @@ export function useWebSocket({ onLog, onStatusChange, filter }: UseWebSocketOpti
             onLog(msg.data as LogEntry)
             break
           case 'status':
-            const status = msg.data as { stdinOpen: boolean }
-            onStatusChange?.(status.stdinOpen)
+            onStatusChange?.(msg.data as InputStatus)
+            break
+          case 'error':
+            console.error('WebSocket subscription rejected:', (msg.data as { error: string }).error)
             break
         }
       } catch (e) {
@@ -0,0 +1,93 @@
+import type { CSSProperties } from 'react'
+import type { LogEntry, StyleSpan } from './api'
+
+export interface StyledSegment {
+  text: string
+  span?: StyleSpan
+}
+
+// The 16 standard terminal colors
+const namedColors: Record<string, string> = {
+  black: '#3f3f46',
+  red: '#f87171',
+  green: '#4ade80',
+  yellow: '#facc15',
+  blue: '#60a5fa',
+  magenta: '#e879f9',
+  cyan: '#22d3ee',
+  white: '#e4e4e7',
+  'bright-black': '#71717a',
+  'bright-red': '#fca5a5',
+  'bright-green': '#86efac',
+  'bright-yellow': '#fde047',
+  'bright-blue': '#93c5fd',
+  'bright-magenta': '#f0abfc',
+  'bright-cyan': '#67e8f9',
+  'bright-white': '#fafafa',
+}
+
+const encoder = new TextEncoder()
+const decoder = new TextDecoder()
+
+function cssColor(color: string | undefined): string | undefined {
+  if (!color) return undefined
+  return namedColors[color] ?? color
+}
+
+/**
+ * Split text, which must be part of entry.raw, into runs carrying the
+ * entry's ANSI styles. Span offsets are bytes of raw, so the text is
+ * located in raw and both are sliced as UTF-8.
+ */
+export function styledSegments(entry: LogEntry, text: string): StyledSegment[] {
+  if (!entry.styles?.length) return [{ text }]
+
+  const at = entry.raw.indexOf(text)
+  if (at < 0) return [{ text }]
+
+  const base = encoder.encode(entry.raw.slice(0, at)).length
+  const bytes = encoder.encode(text)
+  const segments: StyledSegment[] = []
+  let pos = 0
+  const push = (end: number, span?: StyleSpan) => {
+    if (end > pos) segments.push({ text: decoder.decode(bytes.subarray(pos, end)), span })
+    pos = end
+  }
+
+  for (const span of entry.styles) {
+    const start = Math.max(span.start - base, 0)
+    const end = Math.min(span.end - base, bytes.length)
+    if (end <= pos || start >= bytes.length) continue
+    push(Math.max(start, pos))
+    push(end, span)
+  }
+  push(bytes.length)
+  return segments
+}
+
+/**
+ * The OSC 8 hyperlink of a span, if it is safe to open from the viewer
+ */
+export function spanLink(span: StyleSpan | undefined): string | undefined {
+  return span?.link && /^https?:\/\//i.test(span.link) ? span.link : undefined
+}
+
+/**
+ * Inline CSS for a style span
+ */
+export function spanStyle(span: StyleSpan): CSSProperties {
+  const fg = cssColor(span.fg)
+  const bg = cssColor(span.bg)
+  const color = span.inverse ? (bg ?? 'var(--background)') : fg
+  const backgroundColor = span.inverse ? (fg ?? 'var(--foreground)') : bg
+
+  const decorations = [span.underline && 'underline', span.strike && 'line-through'].filter(Boolean)
+  return {
+    color,
+    backgroundColor,
+    fontWeight: span.bold ? 'bold' : undefined,
+    opacity: span.dim ? 0.6 : undefined,
+    fontStyle: span.italic ? 'italic' : undefined,
+    textDecoration: decorations.length ? decorations.join(' ') : undefined,
+  }
+}
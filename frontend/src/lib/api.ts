@@ -2,24 +2,54 @@ export interface LogEntry {
   id: number
   timestamp: string
   raw: string
+  stream?: 'stdout' | 'stderr'
+  styles?: StyleSpan[]
   parsed?: ParsedLog
 }
 
+// ANSI styling of raw[start:end], in UTF-8 byte offsets. Colors are a
+// standard color name ("red", "bright-blue", ...) or "#rrggbb".
+export interface StyleSpan {
+  start: number
+  end: number
+  fg?: string
+  bg?: string
+  bold?: boolean
+  dim?: boolean
+  italic?: boolean
+  underline?: boolean
+  strike?: boolean
+  inverse?: boolean
+  link?: string
+}
+
 export interface ParsedLog {
   time?: string
   level?: string
   message?: string
   source?: string
+  traceId?: string
+  spanId?: string
+  parentSpanId?: string
+  requestId?: string
   fields?: Record<string, unknown>
 }
 
 export interface LogFilter {
   search?: string
+  // Structured query, e.g. `level:>=warn status:5* NOT "health check"`
+  query?: string
   levels?: string[]
+  minLevel?: string
   sources?: string[]
   regex?: boolean
   afterId?: number
   limit?: number
+  // Time or duration ago, e.g. `15m` or `2024-01-15T10:30:00Z`
+  since?: string
+  until?: string
+  timeField?: 'arrival' | 'log'
+  order?: 'arrival' | 'log'
 }
 
 // Docker compose log format: "service-name  | actual log content"
@@ -88,9 +118,45 @@ export interface LogResponse {
 export interface StatusResponse {
   bufferSize: number
   bufferUsed: number
+  bufferBytes: number
+  bufferMaxBytes?: number
+  evictions: number
+  store?: StoreStats
   totalReceived: number
   uptime: string
   stdinOpen: boolean
+  command?: string
+  exitCode?: number
+  levels: LevelInfo[]
+  redactions?: Record<string, number>
+}
+
+export interface TraceResponse {
+  id: string
+  logs: LogEntry[]
+  spans: TraceSpan[]
+}
+
+export interface TraceSpan {
+  spanId: string
+  parentSpanId?: string
+  sources?: string[]
+  logIds: number[]
+  children?: TraceSpan[]
+}
+
+export interface StoreStats {
+  dir: string
+  segments: number
+  bytes: number
+  maxBytes?: number
+  firstId?: number
+  lastId?: number
+}
+
+export interface LevelInfo {
+  name: string
+  priority: number
 }
 
 const BASE_URL = ''
@@ -99,13 +165,24 @@ export async function fetchLogs(filter: LogFilter = {}): Promise<LogResponse> {
   const params = new URLSearchParams()
 
   if (filter.search) params.set('search', filter.search)
+  if (filter.query) params.set('q', filter.query)
   if (filter.levels?.length) params.set('levels', filter.levels.join(','))
+  if (filter.minLevel) params.set('minLevel', filter.minLevel)
+  if (filter.sources?.length) params.set('sources', filter.sources.join(','))
   if (filter.regex) params.set('regex', 'true')
   if (filter.afterId) params.set('afterId', String(filter.afterId))
   if (filter.limit) params.set('limit', String(filter.limit))
+  if (filter.since) params.set('since', filter.since)
+  if (filter.until) params.set('until', filter.until)
+  if (filter.timeField) params.set('timeField', filter.timeField)
+  if (filter.order) params.set('order', filter.order)
 
   const res = await fetch(`${BASE_URL}/api/logs?${params}`)
-  if (!res.ok) throw new Error(`Failed to fetch logs: ${res.statusText}`)
+  if (!res.ok) {
+    // Invalid queries come back as 400 with the syntax error
+    const body = await res.json().catch(() => null)
+    throw new Error(body?.error ?? `Failed to fetch logs: ${res.statusText}`)
+  }
   return res.json()
 }
 
@@ -115,6 +192,12 @@ export async function fetchStatus(): Promise<StatusResponse> {
   return res.json()
 }
 
+export async function fetchTrace(id: string): Promise<TraceResponse> {
+  const res = await fetch(`${BASE_URL}/api/traces/${encodeURIComponent(id)}`)
+  if (!res.ok) throw new Error(`Failed to fetch trace: ${res.statusText}`)
+  return res.json()
+}
+
 export async function clearLogs(): Promise<void> {
   const res = await fetch(`${BASE_URL}/api/logs`, { method: 'DELETE' })
   if (!res.ok) throw new Error(`Failed to clear logs: ${res.statusText}`)
@@ -0,0 +1,338 @@
+// Package ansi turns terminal escape sequences into plain text with style spans
+package ansi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+const esc = 0x1b
+
+// Names of the 16 standard colors; 256-color and truecolor values are
+// returned as "#rrggbb"
+var colorNames = []string{
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+	"bright-black", "bright-red", "bright-green", "bright-yellow",
+	"bright-blue", "bright-magenta", "bright-cyan", "bright-white",
+}
+
+// style is the graphic rendition in effect, without the span offsets
+type style struct {
+	fg, bg    string
+	bold      bool
+	dim       bool
+	italic    bool
+	underline bool
+	strike    bool
+	inverse   bool
+	link      string
+}
+
+// Strip returns s without escape sequences
+func Strip(s string) string {
+	if strings.IndexByte(s, esc) < 0 {
+		return s
+	}
+	plain, _ := Parse(s)
+	return plain
+}
+
+// Parse removes escape sequences from s. SGR sequences (colors, including
+// 256-color and truecolor, bold, dim, italic, underline, strikethrough and
+// inverse) and OSC 8 hyperlinks become spans over the returned text, with
+// byte offsets; other sequences such as cursor movement are dropped.
+func Parse(s string) (string, []models.StyleSpan) {
+	if strings.IndexByte(s, esc) < 0 {
+		return s, nil
+	}
+
+	var (
+		b     strings.Builder
+		spans []models.StyleSpan
+		cur   style
+		start int
+	)
+	// flush closes the run of text written with the current style
+	flush := func() {
+		if end := b.Len(); end > start && cur != (style{}) {
+			spans = appendSpan(spans, cur.span(start, end))
+		}
+		start = b.Len()
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] != esc {
+			j := strings.IndexByte(s[i:], esc)
+			if j < 0 {
+				j = len(s) - i
+			}
+			b.WriteString(s[i : i+j])
+			i += j
+			continue
+		}
+		if i+1 >= len(s) {
+			break
+		}
+
+		switch s[i+1] {
+		case '[': // CSI: parameters, intermediates, one final byte
+			j := i + 2
+			for j < len(s) && (s[j] < 0x40 || s[j] > 0x7e) {
+				j++
+			}
+			if j >= len(s) {
+				i = len(s)
+				continue
+			}
+			if s[j] == 'm' {
+				flush()
+				cur.apply(s[i+2 : j])
+			}
+			i = j + 1
+		case ']': // OSC: terminated by BEL or ST (ESC \)
+			j, end := i+2, len(s)
+			for ; j < len(s); j++ {
+				if s[j] == 0x07 {
+					end = j + 1
+					break
+				}
+				if s[j] == esc && j+1 < len(s) && s[j+1] == '\\' {
+					end = j + 2
+					break
+				}
+			}
+			if rest, ok := strings.CutPrefix(s[i+2:j], "8;"); ok {
+				// OSC 8 ; params ; URI, an empty URI ends the link
+				flush()
+				if _, uri, ok := strings.Cut(rest, ";"); ok {
+					cur.link = uri
+				}
+			}
+			i = end
+		default: // two-byte sequences such as ESC ( B
+			i += 2
+		}
+	}
+	flush()
+	return b.String(), spans
+}
+
+// appendSpan adds span, merging it into the previous one when they touch
+// and share a style
+func appendSpan(spans []models.StyleSpan, span models.StyleSpan) []models.StyleSpan {
+	if n := len(spans); n > 0 && spans[n-1].End == span.Start && sameStyle(spans[n-1], span) {
+		spans[n-1].End = span.End
+		return spans
+	}
+	return append(spans, span)
+}
+
+func sameStyle(a, b models.StyleSpan) bool {
+	a.Start, a.End, b.Start, b.End = 0, 0, 0, 0
+	return a == b
+}
+
+func (st style) span(start, end int) models.StyleSpan {
+	return models.StyleSpan{
+		Start:     start,
+		End:       end,
+		FG:        st.fg,
+		BG:        st.bg,
+		Bold:      st.bold,
+		Dim:       st.dim,
+		Italic:    st.italic,
+		Underline: st.underline,
+		Strike:    st.strike,
+		Inverse:   st.inverse,
+		Link:      st.link,
+	}
+}
+
+// apply updates the style from SGR parameters such as "1;38;5;208".
+// Sub-parameters separated by colons ("38:2::255:0:0") are accepted too.
+func (st *style) apply(params string) {
+	if params == "" {
+		*st = style{link: st.link}
+		return
+	}
+
+	codes := strings.Split(params, ";")
+	for i := 0; i < len(codes); i++ {
+		code, sub, hasSub := strings.Cut(codes[i], ":")
+		n, _ := strconv.Atoi(code)
+		switch {
+		case n == 0:
+			*st = style{link: st.link}
+		case n == 1:
+			st.bold = true
+		case n == 2:
+			st.dim = true
+		case n == 3:
+			st.italic = true
+		case n == 4:
+			// "4:0" turns underlining off, other styles (curly, dotted) are underline
+			st.underline = !hasSub || sub != "0"
+		case n == 7:
+			st.inverse = true
+		case n == 9:
+			st.strike = true
+		case n == 21:
+			st.underline = true // double underline
+		case n == 22:
+			st.bold, st.dim = false, false
+		case n == 23:
+			st.italic = false
+		case n == 24:
+			st.underline = false
+		case n == 27:
+			st.inverse = false
+		case n == 29:
+			st.strike = false
+		case n >= 30 && n <= 37:
+			st.fg = colorNames[n-30]
+		case n == 38, n == 48:
+			var color string
+			if hasSub {
+				color = extendedColor(strings.Split(sub, ":"), true)
+			} else {
+				var used int
+				color, used = extendedColorArgs(codes[i+1:])
+				i += used
+			}
+			if n == 38 {
+				st.fg = color
+			} else {
+				st.bg = color
+			}
+		case n == 39:
+			st.fg = ""
+		case n >= 40 && n <= 47:
+			st.bg = colorNames[n-40]
+		case n == 49:
+			st.bg = ""
+		case n >= 90 && n <= 97:
+			st.fg = colorNames[n-90+8]
+		case n >= 100 && n <= 107:
+			st.bg = colorNames[n-100+8]
+		}
+	}
+}
+
+// extendedColorArgs reads "5;n" or "2;r;g;b" following a 38 or 48 code and
+// returns the color and how many parameters it consumed
+func extendedColorArgs(args []string) (string, int) {
+	if len(args) == 0 {
+		return "", 0
+	}
+	switch args[0] {
+	case "5":
+		if len(args) >= 2 {
+			return extendedColor(args[:2], false), 2
+		}
+	case "2":
+		if len(args) >= 4 {
+			return extendedColor(args[:4], false), 4
+		}
+	}
+	return "", len(args)
+}
+
+// extendedColor decodes the arguments of a 38/48 code. The colon form of
+// truecolor may carry a color space id before r:g:b ("2::r:g:b").
+func extendedColor(args []string, colon bool) string {
+	if len(args) == 0 {
+		return ""
+	}
+	switch args[0] {
+	case "5":
+		if len(args) < 2 {
+			return ""
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 || n > 255 {
+			return ""
+		}
+		return paletteColor(n)
+	case "2":
+		rgb := args[1:]
+		if colon && len(rgb) == 4 {
+			rgb = rgb[1:]
+		}
+		if len(rgb) < 3 {
+			return ""
+		}
+		var c [3]int
+		for i := range c {
+			v, err := strconv.Atoi(rgb[i])
+			if err != nil || v < 0 || v > 255 {
+				return ""
+			}
+			c[i] = v
+		}
+		return fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2])
+	}
+	return ""
+}
+
+// paletteColor returns the xterm 256-color palette entry n; the first 16
+// keep their names so the viewer's theme decides how they look
+func paletteColor(n int) string {
+	switch {
+	case n < 16:
+		return colorNames[n]
+	case n < 232:
+		// 6x6x6 color cube
+		n -= 16
+		levels := [6]int{0, 95, 135, 175, 215, 255}
+		return fmt.Sprintf("#%02x%02x%02x", levels[n/36], levels[n/6%6], levels[n%6])
+	default:
+		// Grayscale ramp
+		v := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", v, v, v)
+	}
+}
+
+// Edit records that text[Start:End] was replaced by Len bytes
+type Edit struct {
+	Start, End, Len int
+}
+
+// Shift moves spans over text to where they fall after edits, which must be
+// in ascending order and not overlap. A span overlapping a replaced range
+// covers its replacement.
+func Shift(spans []models.StyleSpan, edits []Edit) []models.StyleSpan {
+	if len(spans) == 0 || len(edits) == 0 {
+		return spans
+	}
+	out := spans[:0]
+	for _, span := range spans {
+		span.Start = shiftOffset(span.Start, edits, false)
+		span.End = shiftOffset(span.End, edits, true)
+		if span.End > span.Start {
+			out = append(out, span)
+		}
+	}
+	return out
+}
+
+// shiftOffset maps an offset through edits. Offsets inside a replaced range
+// move to its start, or its end for span ends.
+func shiftOffset(off int, edits []Edit, isEnd bool) int {
+	delta := 0
+	for _, e := range edits {
+		switch {
+		case off <= e.Start:
+			return off + delta
+		case off < e.End:
+			if isEnd {
+				return e.Start + delta + e.Len
+			}
+			return e.Start + delta
+		}
+		delta += e.Len - (e.End - e.Start)
+	}
+	return off + delta
+}
@@ -0,0 +1,273 @@
+package buffer
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lch88/logbro/internal/models"
+	"github.com/lch88/logbro/internal/parser"
+)
+
+// maxTrigramLen bounds the part of Raw indexed by trigram; longer entries
+// are kept in a separate list and checked by every substring search
+const maxTrigramLen = 4096
+
+// minCompaction is the fewest evictions before postings are compacted
+const minCompaction = 4096
+
+// postingSize is the memory a posting takes, counted in the entry's size
+const postingSize = 8
+
+// queryIndex holds postings lists of entry IDs, in ascending order, for the
+// properties queries filter on. Evicted IDs are dropped lazily: queries skip
+// IDs below the oldest buffered one, and compact removes them once enough
+// have accumulated.
+type queryIndex struct {
+	levels   map[string][]uint64
+	sources  map[string][]uint64
+	trigrams map[uint32][]uint64 // ASCII-lowercased byte trigrams of Raw
+	long     []uint64            // entries too long for the trigram index
+	stale    int                 // evictions since the last compaction
+}
+
+func newQueryIndex() *queryIndex {
+	return &queryIndex{
+		levels:   make(map[string][]uint64),
+		sources:  make(map[string][]uint64),
+		trigrams: make(map[uint32][]uint64),
+	}
+}
+
+// add indexes an entry and returns the number of postings it took
+func (ix *queryIndex) add(entry models.LogEntry) int {
+	n := 0
+	if p := entry.Parsed; p != nil {
+		if p.Level != "" {
+			ix.levels[p.Level] = append(ix.levels[p.Level], entry.ID)
+			n++
+		}
+		if p.Source != "" {
+			ix.sources[p.Source] = append(ix.sources[p.Source], entry.ID)
+			n++
+		}
+	}
+
+	if len(entry.Raw) > maxTrigramLen {
+		ix.long = append(ix.long, entry.ID)
+		return n + 1
+	}
+	text := entry.Raw
+	if !isASCII(text) {
+		// Searches match the Unicode lower-case form, where other bytes can
+		// turn into ASCII letters (the Kelvin sign becomes k)
+		text = strings.ToLower(text)
+	}
+	for _, t := range trigrams(text) {
+		ix.trigrams[t] = append(ix.trigrams[t], entry.ID)
+		n++
+	}
+	return n
+}
+
+// evicted notes an eviction, compacting the postings once the stale IDs
+// outnumber the buffered entries
+func (ix *queryIndex) evicted(oldest uint64, count int) {
+	ix.stale++
+	if ix.stale < max(count, minCompaction) {
+		return
+	}
+	ix.stale = 0
+	compactPostings(ix.levels, oldest)
+	compactPostings(ix.sources, oldest)
+	compactPostings(ix.trigrams, oldest)
+	ix.long = trimPostings(ix.long, oldest)
+}
+
+func compactPostings[K comparable](m map[K][]uint64, oldest uint64) {
+	for k, ids := range m {
+		if ids = trimPostings(ids, oldest); len(ids) == 0 {
+			delete(m, k)
+		} else {
+			m[k] = ids
+		}
+	}
+}
+
+// trimPostings drops IDs below oldest, copying the rest so the evicted
+// prefix can be freed
+func trimPostings(ids []uint64, oldest uint64) []uint64 {
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= oldest })
+	if i == 0 {
+		return ids
+	}
+	return append([]uint64(nil), ids[i:]...)
+}
+
+// trigrams returns the distinct trigrams of s, with ASCII letters
+// lower-cased, in ascending order. Lines with other bytes are indexed in
+// their Unicode lower-case form.
+func trigrams(s string) []uint32 {
+	if len(s) < 3 {
+		return nil
+	}
+	ts := make([]uint32, 0, len(s)-2)
+	for i := 0; i+2 < len(s); i++ {
+		ts = append(ts, uint32(lowerASCII(s[i]))<<16|uint32(lowerASCII(s[i+1]))<<8|uint32(lowerASCII(s[i+2])))
+	}
+	slices.Sort(ts)
+	return slices.Compact(ts)
+}
+
+// searchTrigrams returns the trigrams every entry containing needle
+// case-insensitively must have. Trigrams with non-ASCII bytes are skipped,
+// as Unicode case folding can change them.
+func searchTrigrams(needle string) []uint32 {
+	var ts []uint32
+	for _, t := range trigrams(needle) {
+		if t&0x808080 == 0 {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func lowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// candidates returns the IDs of entries that may match filter, in
+// ascending order, or all=true when the index can't narrow them down.
+// terms is text every match contains, from the filter's query. Only
+// conditions the index covers are used; matches must be confirmed.
+func (ix *queryIndex) candidates(filter models.LogFilter, terms []string) (ids []uint64, all bool) {
+	var lists [][]uint64
+
+	if len(filter.Levels) > 0 || filter.MinLevel != "" {
+		var levelLists [][]uint64
+		for level, postings := range ix.levels {
+			if len(filter.Levels) > 0 && !containsLevel(filter.Levels, level) {
+				continue
+			}
+			if filter.MinLevel != "" && !parser.LevelAtLeast(level, filter.MinLevel) {
+				continue
+			}
+			levelLists = append(levelLists, postings)
+		}
+		lists = append(lists, union(levelLists))
+	}
+
+	if len(filter.Sources) > 0 {
+		var sourceLists [][]uint64
+		for _, source := range filter.Sources {
+			sourceLists = append(sourceLists, ix.sources[source])
+		}
+		lists = append(lists, union(sourceLists))
+	}
+
+	if filter.Search != "" {
+		terms = append(terms, searchLiteral(filter))
+	}
+	for _, term := range terms {
+		if ts := searchTrigrams(term); len(ts) > 0 {
+			trigramLists := make([][]uint64, len(ts))
+			for i, t := range ts {
+				trigramLists[i] = ix.trigrams[t]
+			}
+			lists = append(lists, union([][]uint64{intersect(trigramLists), ix.long}))
+		}
+	}
+
+	if len(lists) == 0 {
+		return nil, true
+	}
+	return intersect(lists), false
+}
+
+// searchLiteral returns text every match of the search must contain: the
+// search itself, or the literal prefix of a regex search
+func searchLiteral(filter models.LogFilter) string {
+	if !filter.Regex {
+		return filter.Search
+	}
+	re, err := regexp.Compile(filter.Search)
+	if err != nil {
+		return filter.Search // matched literally, like newMatcher does
+	}
+	prefix, _ := re.LiteralPrefix()
+	return prefix
+}
+
+// intersect returns the IDs present in every list
+func intersect(lists [][]uint64) []uint64 {
+	if len(lists) == 0 {
+		return nil
+	}
+	slices.SortFunc(lists, func(a, b []uint64) int { return len(a) - len(b) })
+
+	result := slices.Clone(lists[0])
+	for _, list := range lists[1:] {
+		out := result[:0]
+		for _, id := range result {
+			// Galloping would help for very uneven lists; binary search is
+			// enough as the shortest list drives the loop
+			if _, found := slices.BinarySearch(list, id); found {
+				out = append(out, id)
+			}
+		}
+		result = out
+		if len(result) == 0 {
+			break
+		}
+	}
+	return result
+}
+
+// union merges sorted lists into one sorted list without duplicates
+func union(lists [][]uint64) []uint64 {
+	var result []uint64
+	for i, list := range lists {
+		if i == 0 {
+			result = list
+			continue
+		}
+		result = merge(result, list)
+	}
+	return result
+}
+
+// merge merges two sorted lists, dropping duplicates
+func merge(a, b []uint64) []uint64 {
+	result := make([]uint64, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			result = append(result, a[i])
+			i++
+		case a[i] > b[j]:
+			result = append(result, b[j])
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	result = append(result, a[i:]...)
+	return append(result, b[j:]...)
+}
@@ -1,33 +1,120 @@
 package buffer
 
 import (
+	"fmt"
+	"log"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/lch88/logbro/internal/models"
+	"github.com/lch88/logbro/internal/parser"
+	"github.com/lch88/logbro/internal/query"
 )
 
-// Ring is a thread-safe ring buffer for log entries
+// Ring is a thread-safe ring buffer for log entries, bounded by entry count,
+// estimated memory size or both
 type Ring struct {
 	mu            sync.RWMutex
-	entries       []models.LogEntry
-	capacity      int
-	head          int    // next write position
+	slots         []slot // circular, grows up to capacity
+	capacity      int    // max entries, 0 for no count limit
+	maxBytes      int64  // max estimated size, 0 for no size limit
+	head          int    // position of the oldest entry
 	count         int    // current number of entries
+	bytes         int64  // estimated size of the current entries
 	totalReceived uint64 // total logs received (monotonic ID source)
+	evictions     uint64 // entries dropped to stay within the limits
+
+	store    Store
+	writeAll bool // store every entry on arrival rather than on eviction
+
+	index *queryIndex
+
+	// traces maps trace and request ids to the IDs of buffered entries
+	// carrying them, oldest first
+	traces map[string][]uint64
+}
+
+// slot is a buffered entry with its estimated size
+type slot struct {
+	entry models.LogEntry
+	size  int64
+}
+
+// initialSlots bounds the slots allocated up front
+const initialSlots = 1024
+
+// Option configures a Ring
+type Option func(*Ring)
+
+// WithMaxBytes evicts the oldest entries once the estimated size of the
+// buffer exceeds n bytes; the newest entry is always kept
+func WithMaxBytes(n int64) Option {
+	return func(r *Ring) {
+		r.maxBytes = n
+	}
 }
 
-// New creates a new ring buffer with given capacity
-func New(capacity int) *Ring {
-	return &Ring{
-		entries:  make([]models.LogEntry, capacity),
+// Store persists entries beyond the ring, see store.Store
+type Store interface {
+	// Append queues an entry for writing, without blocking on disk I/O, and
+	// makes it visible to Scan; entries arrive in ID order
+	Append(entry models.LogEntry) error
+	// Scan calls fn for entries with afterID < ID < beforeID in ID order
+	// until it returns false. Entries outside tr may be skipped.
+	Scan(afterID, beforeID uint64, tr models.TimeRange, fn func(models.LogEntry) bool) error
+	// LastID is the highest ID stored, new entries are numbered after it
+	LastID() uint64
+	// Clear deletes every stored entry
+	Clear() error
+	Stats() models.StoreStats
+	Close() error
+}
+
+// WithStore writes evicted entries to st, where queries reaching past the
+// buffered entries find them. IDs continue from the last stored entry.
+func WithStore(st Store) Option {
+	return func(r *Ring) {
+		r.store = st
+	}
+}
+
+// WithWriteThrough makes the store (see WithStore) receive every entry as
+// it arrives instead of on eviction
+func WithWriteThrough() Option {
+	return func(r *Ring) {
+		r.writeAll = true
+	}
+}
+
+// New creates a new ring buffer holding up to capacity entries; a capacity
+// of 0 leaves the count unbounded, for use with WithMaxBytes
+func New(capacity int, opts ...Option) *Ring {
+	r := &Ring{
 		capacity: capacity,
+		index:    newQueryIndex(),
+		traces:   make(map[string][]uint64),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	if r.store != nil {
+		r.totalReceived = r.store.LastID()
+	}
+
+	n := initialSlots
+	if capacity > 0 && capacity < n {
+		n = capacity
+	}
+	r.slots = make([]slot, n)
+	return r
 }
 
-// Add inserts a new log entry, overwriting oldest if full
-// Returns the entry with its assigned ID
+// Add inserts a new log entry, evicting the oldest ones to stay within the
+// limits. Returns the entry with its assigned ID
 func (r *Ring) Add(entry models.LogEntry) models.LogEntry {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -35,44 +122,208 @@ func (r *Ring) Add(entry models.LogEntry) models.LogEntry {
 	r.totalReceived++
 	entry.ID = r.totalReceived
 
-	r.entries[r.head] = entry
-	r.head = (r.head + 1) % r.capacity
+	if r.capacity > 0 && r.count == r.capacity {
+		r.evictOldest()
+	}
+	if r.count == len(r.slots) {
+		r.grow()
+	}
 
-	if r.count < r.capacity {
-		r.count++
+	size := EstimateSize(entry) + int64(r.index.add(entry))*postingSize
+	r.slots[(r.head+r.count)%len(r.slots)] = slot{entry: entry, size: size}
+	r.count++
+	r.bytes += size
+	r.indexTrace(entry)
+	if r.writeAll {
+		r.persist(entry)
+	}
+
+	for r.maxBytes > 0 && r.bytes > r.maxBytes && r.count > 1 {
+		r.evictOldest()
 	}
 
 	return entry
 }
 
+// evictOldest drops the oldest entry
+func (r *Ring) evictOldest() {
+	s := &r.slots[r.head]
+	if r.store != nil && !r.writeAll {
+		r.persist(s.entry)
+	}
+	r.unindexTrace(s.entry)
+	r.bytes -= s.size
+	*s = slot{} // release the entry's memory
+	r.head = (r.head + 1) % len(r.slots)
+	r.count--
+	r.evictions++
+	r.index.evicted(r.oldestID(), r.count)
+}
+
+// persist queues an entry in the store, which writes it in the background
+func (r *Ring) persist(entry models.LogEntry) {
+	if err := r.store.Append(entry); err != nil {
+		log.Printf("Store write error: %v", err)
+	}
+}
+
+// grow doubles the slots, up to capacity
+func (r *Ring) grow() {
+	n := 2 * len(r.slots)
+	if r.capacity > 0 && n > r.capacity {
+		n = r.capacity
+	}
+	slots := make([]slot, n)
+	for i := 0; i < r.count; i++ {
+		slots[i] = r.slots[(r.head+i)%len(r.slots)]
+	}
+	r.slots = slots
+	r.head = 0
+}
+
+// at returns the i-th oldest entry
+func (r *Ring) at(i int) models.LogEntry {
+	return r.slots[(r.head+i)%len(r.slots)].entry
+}
+
 // GetAll returns all entries in chronological order
 func (r *Ring) GetAll() []models.LogEntry {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	if r.count == 0 {
-		return []models.LogEntry{}
+	result := make([]models.LogEntry, r.count)
+	for i := range result {
+		result[i] = r.at(i)
 	}
+	return result
+}
 
-	result := make([]models.LogEntry, r.count)
-	start := 0
-	if r.count == r.capacity {
-		start = r.head // oldest entry is at head when buffer is full
+// Query returns filtered entries. Level, source and search conditions are
+// looked up in the index, so only candidate entries are examined. With a
+// store, queries whose AfterID lies before the oldest buffered entry, or
+// that have a time range, also read the evicted entries from it. Results
+// are in ID order, or log time order when filter.Order is "log". Total is
+// exact unless the store was read in ID order; that stops once more than
+// the limit match, with Total counting the entries returned.
+func (r *Ring) Query(filter models.LogFilter) (models.LogResponse, error) {
+	now := time.Now()
+	expr, err := query.Compile(filter, now)
+	if err != nil {
+		return models.LogResponse{}, err
 	}
-	for i := 0; i < r.count; i++ {
-		idx := (start + i) % r.capacity
-		result[i] = r.entries[idx]
+	tr, _ := query.Range(filter, now) // checked by Compile
+	byLogTime := false
+	switch filter.Order {
+	case "", query.TimeArrival:
+	case query.TimeLog:
+		byLogTime = true
+	default:
+		return models.LogResponse{}, fmt.Errorf("invalid order %q, want %q or %q", filter.Order, query.TimeArrival, query.TimeLog)
 	}
-	return result
+
+	match := newMatcher(filter, expr)
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 1000
+	}
+
+	filtered := []models.LogEntry{}
+	total := 0
+	collect := func(entry models.LogEntry) bool {
+		if !match(entry) {
+			return true
+		}
+		total++
+		if !byLogTime {
+			if len(filtered) < limit {
+				filtered = append(filtered, entry)
+			}
+			return true
+		}
+		// Keep the earliest by log time, trimming in batches
+		filtered = append(filtered, entry)
+		if len(filtered) >= 2*limit {
+			filtered = sortByLogTime(filtered)[:limit]
+		}
+		return true
+	}
+
+	// Reading the store decompresses blocks, so in ID order it stops at the
+	// first match past the limit instead of counting every match
+	stopped := false
+	collectStored := func(entry models.LogEntry) bool {
+		if !byLogTime && len(filtered) == limit && match(entry) {
+			stopped = true
+			return false
+		}
+		return collect(entry)
+	}
+	more := func() (models.LogResponse, error) {
+		return models.LogResponse{Logs: filtered, Total: total, HasMore: true}, nil
+	}
+
+	r.mu.RLock()
+	oldest := r.oldestID()
+	r.mu.RUnlock()
+
+	// Evicted entries come first; the store is read without holding the lock
+	readStore := r.store != nil && (filter.AfterID > 0 || !tr.Since.IsZero() || !tr.Until.IsZero())
+	scanned := filter.AfterID
+	if readStore && scanned+1 < oldest {
+		r.scanStore(scanned, oldest, tr, collectStored)
+		if stopped {
+			return more()
+		}
+		scanned = oldest - 1
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	oldest = r.oldestID()
+	if readStore && scanned+1 < oldest {
+		// Evicted while the store was read
+		r.scanStore(scanned, oldest, tr, collectStored)
+		if stopped {
+			return more()
+		}
+	}
+
+	// Buffered IDs are consecutive from the oldest entry's
+	first := max(oldest, filter.AfterID+1)
+	ids, all := r.index.candidates(filter, query.Terms(expr))
+	if all {
+		for id := first; id < oldest+uint64(r.count); id++ {
+			collect(r.at(int(id - oldest)))
+		}
+	} else {
+		i, _ := slices.BinarySearch(ids, first)
+		for _, id := range ids[i:] {
+			collect(r.at(int(id - oldest)))
+		}
+	}
+
+	if byLogTime {
+		filtered = sortByLogTime(filtered)
+		filtered = filtered[:min(len(filtered), limit)]
+	}
+	return models.LogResponse{
+		Logs:    filtered,
+		Total:   total,
+		HasMore: total > limit,
+	}, nil
 }
 
-// Query returns filtered entries
-func (r *Ring) Query(filter models.LogFilter) models.LogResponse {
-	all := r.GetAll()
+// sortByLogTime orders entries by log time, then ID
+func sortByLogTime(entries []models.LogEntry) []models.LogEntry {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return query.LogTime(entries[i]).Before(query.LogTime(entries[j]))
+	})
+	return entries
+}
 
-	var filtered []models.LogEntry
+// newMatcher compiles filter and its parsed query into a predicate
+func newMatcher(filter models.LogFilter, expr query.Expr) func(models.LogEntry) bool {
 	var searchRegex *regexp.Regexp
-
 	if filter.Regex && filter.Search != "" {
 		var err error
 		searchRegex, err = regexp.Compile(filter.Search)
@@ -83,70 +334,175 @@ func (r *Ring) Query(filter models.LogFilter) models.LogResponse {
 	}
 
 	searchLower := strings.ToLower(filter.Search)
+	sources := make(map[string]bool, len(filter.Sources))
+	for _, source := range filter.Sources {
+		sources[source] = true
+	}
 
-	for _, entry := range all {
+	return func(entry models.LogEntry) bool {
 		// Skip entries before afterID
 		if filter.AfterID > 0 && entry.ID <= filter.AfterID {
-			continue
+			return false
 		}
 
 		// Filter by level
 		if len(filter.Levels) > 0 {
 			if entry.Parsed == nil || !containsLevel(filter.Levels, entry.Parsed.Level) {
-				continue
+				return false
 			}
 		}
 
+		if filter.MinLevel != "" {
+			if entry.Parsed == nil || !parser.LevelAtLeast(entry.Parsed.Level, filter.MinLevel) {
+				return false
+			}
+		}
+
+		if len(sources) > 0 && (entry.Parsed == nil || !sources[entry.Parsed.Source]) {
+			return false
+		}
+
 		// Filter by search
 		if filter.Search != "" {
 			if searchRegex != nil {
 				if !searchRegex.MatchString(entry.Raw) {
-					continue
+					return false
 				}
-			} else if !strings.Contains(strings.ToLower(entry.Raw), searchLower) {
-				continue
+			} else if !query.ContainsFold(entry.Raw, searchLower) {
+				return false
 			}
 		}
 
-		filtered = append(filtered, entry)
+		return expr == nil || expr.Match(&entry)
 	}
+}
 
-	total := len(filtered)
-	limit := filter.Limit
-	if limit <= 0 {
-		limit = 1000
-	}
+// Stats returns buffer statistics
+func (r *Ring) Stats() (capacity, used int, totalReceived uint64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.capacity, r.count, r.totalReceived
+}
 
-	hasMore := len(filtered) > limit
-	if hasMore {
-		filtered = filtered[:limit]
+// MemoryStats returns the estimated size of the buffered entries, the size
+// limit (0 when unbounded) and how many entries have been evicted
+func (r *Ring) MemoryStats() (bytes, maxBytes int64, evictions uint64) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.bytes, r.maxBytes, r.evictions
+}
+
+// StoreStats describes the store, or returns nil without one
+func (r *Ring) StoreStats() *models.StoreStats {
+	if r.store == nil {
+		return nil
 	}
+	stats := r.store.Stats()
+	return &stats
+}
 
-	if filtered == nil {
-		filtered = []models.LogEntry{}
+// Close writes the buffered entries not stored yet and closes the store
+func (r *Ring) Close() error {
+	if r.store == nil {
+		return nil
 	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.writeAll {
+		for i := 0; i < r.count; i++ {
+			r.persist(r.at(i))
+		}
+	}
+	return r.store.Close()
+}
 
-	return models.LogResponse{
-		Logs:    filtered,
-		Total:   total,
-		HasMore: hasMore,
+// oldestID is the ID of the oldest buffered entry, or the next ID when the
+// buffer is empty
+func (r *Ring) oldestID() uint64 {
+	if r.count == 0 {
+		return r.totalReceived + 1
 	}
+	return r.at(0).ID
 }
 
-// Stats returns buffer statistics
-func (r *Ring) Stats() (capacity, used int, totalReceived uint64) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	return r.capacity, r.count, r.totalReceived
+// scanStore reads entries with afterID < ID < beforeID from the store,
+// skipping blocks outside tr
+func (r *Ring) scanStore(afterID, beforeID uint64, tr models.TimeRange, fn func(models.LogEntry) bool) {
+	if err := r.store.Scan(afterID, beforeID, tr, fn); err != nil {
+		log.Printf("Store read error: %v", err)
+	}
 }
 
 // Clear removes all entries from the buffer
 func (r *Ring) Clear() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	clear(r.slots)
 	r.head = 0
 	r.count = 0
+	r.bytes = 0
+	r.index = newQueryIndex()
+	clear(r.traces)
 	// Note: totalReceived is not reset to maintain monotonic IDs
+
+	if r.store != nil {
+		if err := r.store.Clear(); err != nil {
+			log.Printf("Store clear error: %v", err)
+		}
+	}
+}
+
+// Trace returns the buffered entries whose trace id or request id is id,
+// in arrival order
+func (r *Ring) Trace(id string) []models.LogEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := r.traces[id]
+	result := make([]models.LogEntry, 0, len(ids))
+	// Buffered IDs are consecutive from the oldest entry's
+	oldest := r.oldestID()
+	for _, entryID := range ids {
+		result = append(result, r.at(int(entryID-oldest)))
+	}
+	return result
+}
+
+// correlationIDs returns the ids an entry is indexed under
+func correlationIDs(entry models.LogEntry) []string {
+	p := entry.Parsed
+	if p == nil {
+		return nil
+	}
+	var ids []string
+	if p.TraceID != "" {
+		ids = append(ids, p.TraceID)
+	}
+	if p.RequestID != "" && p.RequestID != p.TraceID {
+		ids = append(ids, p.RequestID)
+	}
+	return ids
+}
+
+func (r *Ring) indexTrace(entry models.LogEntry) {
+	for _, id := range correlationIDs(entry) {
+		r.traces[id] = append(r.traces[id], entry.ID)
+	}
+}
+
+// unindex removes an evicted entry, which is always the oldest for its ids
+func (r *Ring) unindexTrace(entry models.LogEntry) {
+	for _, id := range correlationIDs(entry) {
+		ids := r.traces[id]
+		if len(ids) > 0 && ids[0] == entry.ID {
+			ids = ids[1:]
+		}
+		if len(ids) == 0 {
+			delete(r.traces, id)
+		} else {
+			r.traces[id] = ids
+		}
+	}
 }
 
 func containsLevel(levels []string, level string) bool {
@@ -0,0 +1,115 @@
+package buffer
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+const benchEntries = 1_000_000
+
+var (
+	benchLevels = []string{"DEBUG", "INFO", "INFO", "INFO", "WARN", "ERROR"}
+
+	benchOnce sync.Once
+	benchRing *Ring
+)
+
+// filledRing returns a ring of 1M entries spread over levels and sources,
+// built once for all benchmarks
+func filledRing() *Ring {
+	benchOnce.Do(func() {
+		benchRing = New(benchEntries)
+		now := time.Now()
+		for i := range benchEntries {
+			benchRing.Add(models.LogEntry{
+				Timestamp: now,
+				Raw:       fmt.Sprintf("GET /api/users/%d status=200 took %dms user=u%d", i, i%500, i%1000),
+				Parsed: &models.ParsedLog{
+					Level:   benchLevels[i%len(benchLevels)],
+					Source:  fmt.Sprintf("svc-%d", i%20),
+					Message: "request",
+				},
+			})
+		}
+	})
+	return benchRing
+}
+
+// scanQuery answers filter by checking every buffered entry, as queries did
+// before the index
+func scanQuery(r *Ring, filter models.LogFilter) []uint64 {
+	match := newMatcher(filter, nil)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ids []uint64
+	for i := range r.count {
+		if entry := r.at(i); match(entry) {
+			ids = append(ids, entry.ID)
+		}
+	}
+	return ids
+}
+
+func benchmarkQuery(b *testing.B, filter models.LogFilter) {
+	r := filledRing()
+	filter.Limit = 1000
+	b.Run("scan", func(b *testing.B) {
+		for b.Loop() {
+			scanQuery(r, filter)
+		}
+	})
+	b.Run("indexed", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := r.Query(filter); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkQueryLevel(b *testing.B) {
+	benchmarkQuery(b, models.LogFilter{Levels: []string{"ERROR"}})
+}
+
+func BenchmarkQuerySource(b *testing.B) {
+	benchmarkQuery(b, models.LogFilter{Sources: []string{"svc-3"}})
+}
+
+func BenchmarkQuerySearch(b *testing.B) {
+	benchmarkQuery(b, models.LogFilter{Search: "users/12345 "})
+}
+
+func TestQueryMatchesScan(t *testing.T) {
+	r := New(100)
+	lines := []string{
+		"plain ascii Kelvin",
+		"\u212Aelvin sign", // folds to "kelvin"
+		"café KELVIN",      // non-ASCII before the match
+		"a\u212A spans it", // match crosses the non-ASCII rune
+		"nothing here",
+	}
+	for _, line := range lines {
+		r.Add(models.LogEntry{Raw: line, Parsed: &models.ParsedLog{Level: "INFO"}})
+	}
+
+	for _, search := range []string{"kelvin", "KELVIN", "ak", "café", "here"} {
+		filter := models.LogFilter{Search: search}
+		resp, err := r.Query(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []uint64
+		for _, entry := range resp.Logs {
+			got = append(got, entry.ID)
+		}
+		if want := scanQuery(r, filter); !slices.Equal(got, want) {
+			t.Errorf("search %q: indexed %v, scan %v", search, got, want)
+		}
+	}
+}
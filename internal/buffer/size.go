@@ -0,0 +1,65 @@
+package buffer
+
+import (
+	"unsafe"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+// Approximate in-memory overheads, in bytes, of the values making up an entry
+var (
+	entrySize     = int64(unsafe.Sizeof(slot{}))
+	parsedSize    = int64(unsafe.Sizeof(models.ParsedLog{}))
+	styleSpanSize = int64(unsafe.Sizeof(models.StyleSpan{}))
+)
+
+const (
+	mapEntryOverhead = 48 // bucket share, key and value headers
+	valueOverhead    = 16 // interface header
+)
+
+// EstimateSize approximates the memory an entry holds: the raw line, ANSI
+// spans and the parsed message and fields. Strings shared between Raw and
+// the parsed values are counted twice, erring on the side of evicting early.
+func EstimateSize(entry models.LogEntry) int64 {
+	size := entrySize + int64(len(entry.Raw)+len(entry.Stream))
+	for _, span := range entry.Styles {
+		size += styleSpanSize + int64(len(span.FG)+len(span.BG)+len(span.Link))
+	}
+
+	if p := entry.Parsed; p != nil {
+		size += parsedSize + int64(len(p.Level)+len(p.Message)+len(p.Source)+
+			len(p.TraceID)+len(p.SpanID)+len(p.ParentSpanID)+len(p.RequestID))
+		if p.Time != nil {
+			size += int64(unsafe.Sizeof(*p.Time))
+		}
+		size += fieldsSize(p.Fields)
+	}
+	return size
+}
+
+func fieldsSize(fields map[string]any) int64 {
+	var size int64
+	for k, v := range fields {
+		size += mapEntryOverhead + int64(len(k)) + valueSize(v)
+	}
+	return size
+}
+
+func valueSize(v any) int64 {
+	switch tv := v.(type) {
+	case string:
+		return valueOverhead + int64(len(tv))
+	case []byte:
+		return valueOverhead + int64(len(tv))
+	case map[string]any:
+		return valueOverhead + fieldsSize(tv)
+	case []any:
+		size := valueOverhead + int64(24)
+		for _, item := range tv {
+			size += valueSize(item)
+		}
+		return size
+	}
+	return valueOverhead + 8
+}
@@ -0,0 +1,307 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Limits on untrusted input
+const (
+	// maxMsgpackLen bounds any single string, binary, array or map length (64MB)
+	maxMsgpackLen = 64 * 1024 * 1024
+	// MaxMsgpackBytes bounds the memory one top-level value may allocate (64MB)
+	MaxMsgpackBytes = 64 * 1024 * 1024
+	// maxMsgpackDepth bounds the nesting of arrays and maps
+	maxMsgpackDepth = 100
+	// msgpackValueCost is charged per decoded value, approximating its
+	// interface and container overhead
+	msgpackValueCost = 16
+	// msgpackChunk is the largest payload allocated before it is read;
+	// longer ones grow with the data actually received
+	msgpackChunk = 64 * 1024
+)
+
+// MsgpackExt is a msgpack extension value, such as Fluentd's EventTime (type 0)
+type MsgpackExt struct {
+	Type int8
+	Data []byte
+}
+
+// MsgpackDecoder reads consecutive msgpack values from a stream. Maps decode
+// to map[string]any (non-string keys are formatted), arrays to []any,
+// integers to int64 or uint64, floats to float64, str to string and bin to []byte.
+type MsgpackDecoder struct {
+	r      *bufio.Reader
+	depth  int // arrays and maps being decoded
+	budget int // bytes the current top-level value may still allocate
+}
+
+// NewMsgpackDecoder creates a decoder reading from r
+func NewMsgpackDecoder(r io.Reader) *MsgpackDecoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &MsgpackDecoder{r: br}
+}
+
+// Decode reads the next value; it returns io.EOF only at a clean value boundary
+func (d *MsgpackDecoder) Decode() (any, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	d.depth, d.budget = 0, MaxMsgpackBytes
+	v, err := d.decodeValue(b)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+func (d *MsgpackDecoder) decodeValue(b byte) (any, error) {
+	if err := d.charge(msgpackValueCost); err != nil {
+		return nil, err
+	}
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b >= 0x80 && b <= 0x8f:
+		return d.readMap(int(b & 0x0f))
+	case b >= 0x90 && b <= 0x9f:
+		return d.readArray(int(b & 0x0f))
+	case b >= 0xa0 && b <= 0xbf:
+		return d.readString(int(b & 0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readLen(b - 0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return d.readBytes(n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readLen(b - 0xc7)
+		if err != nil {
+			return nil, err
+		}
+		return d.readExt(n)
+	case 0xca:
+		u, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.readUint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce:
+		u, err := d.readUint(1 << (b - 0xcc))
+		return int64(u), err
+	case 0xcf:
+		u, err := d.readUint(8)
+		if u > math.MaxInt64 {
+			return u, err
+		}
+		return int64(u), err
+	case 0xd0:
+		u, err := d.readUint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.readUint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.readUint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.readUint(8)
+		return int64(u), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.readExt(1 << (b - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readLen(b - 0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return d.readString(n)
+	case 0xdc, 0xdd:
+		n, err := d.readLen(b - 0xdc + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.readArray(n)
+	case 0xde, 0xdf:
+		n, err := d.readLen(b - 0xde + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.readMap(n)
+	}
+
+	return nil, fmt.Errorf("msgpack: unknown type byte 0x%02x", b)
+}
+
+// readLen reads a 1, 2 or 4 byte big-endian length (size index 0, 1 or 2)
+func (d *MsgpackDecoder) readLen(sizeIndex byte) (int, error) {
+	u, err := d.readUint(1 << sizeIndex)
+	if err != nil {
+		return 0, err
+	}
+	if u > maxMsgpackLen {
+		return 0, fmt.Errorf("msgpack: length %d too large", u)
+	}
+	return int(u), nil
+}
+
+func (d *MsgpackDecoder) readUint(size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(d.r, buf[:size]); err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(buf[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(buf[:2])), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(buf[:4])), nil
+	default:
+		return binary.BigEndian.Uint64(buf[:8]), nil
+	}
+}
+
+// charge takes n bytes from the value's budget before they are allocated
+func (d *MsgpackDecoder) charge(n int) error {
+	if n > d.budget {
+		return fmt.Errorf("msgpack: value exceeds %d bytes", MaxMsgpackBytes)
+	}
+	d.budget -= n
+	return nil
+}
+
+// nest enters an array or map, failing beyond maxMsgpackDepth levels
+func (d *MsgpackDecoder) nest() error {
+	if d.depth >= maxMsgpackDepth {
+		return fmt.Errorf("msgpack: nesting deeper than %d levels", maxMsgpackDepth)
+	}
+	d.depth++
+	return nil
+}
+
+func (d *MsgpackDecoder) readBytes(n int) ([]byte, error) {
+	if err := d.charge(n); err != nil {
+		return nil, err
+	}
+	if n <= msgpackChunk {
+		buf := make([]byte, n)
+		_, err := io.ReadFull(d.r, buf)
+		return buf, err
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *MsgpackDecoder) readString(n int) (string, error) {
+	buf, err := d.readBytes(n)
+	return string(buf), err
+}
+
+func (d *MsgpackDecoder) readExt(n int) (MsgpackExt, error) {
+	t, err := d.r.ReadByte()
+	if err != nil {
+		return MsgpackExt{}, err
+	}
+	data, err := d.readBytes(n)
+	return MsgpackExt{Type: int8(t), Data: data}, err
+}
+
+func (d *MsgpackDecoder) readArray(n int) ([]any, error) {
+	if err := d.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+
+	arr := make([]any, 0, min(n, 1024))
+	for i := 0; i < n; i++ {
+		v, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
+
+func (d *MsgpackDecoder) readMap(n int) (map[string]any, error) {
+	if err := d.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+
+	m := make(map[string]any, min(n, 1024))
+	for i := 0; i < n; i++ {
+		k, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		switch key := k.(type) {
+		case string:
+			m[key] = v
+		case []byte:
+			m[string(key)] = v
+		default:
+			m[fmt.Sprint(key)] = v
+		}
+	}
+	return m, nil
+}
+
+// next decodes a nested value, where EOF is always unexpected
+func (d *MsgpackDecoder) next() (any, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return d.decodeValue(b)
+}
+
+// AppendMsgpackString appends s encoded as a msgpack str
+func AppendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xda)
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b = append(b, 0xdb)
+		b = binary.BigEndian.AppendUint32(b, uint32(n))
+	}
+	return append(b, s...)
+}
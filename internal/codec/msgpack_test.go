@@ -0,0 +1,117 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMsgpackDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want any
+	}{
+		{"fixmap", []byte{0x81, 0xa1, 'a', 0x01}, map[string]any{"a": int64(1)}},
+		{"fixarray", []byte{0x93, 0xc3, 0xc0, 0xa1, 'x'}, []any{true, nil, "x"}},
+		{"negative fixint", []byte{0xe0}, int64(-32)},
+		{"int8", []byte{0xd0, 0x80}, int64(-128)},
+		{"uint16", []byte{0xcd, 0x01, 0x00}, int64(256)},
+		{"uint64 above int64", []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, uint64(1<<64 - 1)},
+		{"float64", []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, 1.5},
+		{"float32", []byte{0xca, 0x3f, 0xc0, 0, 0}, 1.5},
+		{"str8", []byte{0xd9, 0x02, 'h', 'i'}, "hi"},
+		{"bin8", []byte{0xc4, 0x03, 1, 2, 3}, []byte{1, 2, 3}},
+		{"fixext1", []byte{0xd4, 0x00, 0x05}, MsgpackExt{Type: 0, Data: []byte{5}}},
+		{"integer map key", []byte{0x81, 0x01, 0x02}, map[string]any{"1": int64(2)}},
+		{"map16", []byte{0xde, 0x00, 0x01, 0xa1, 'k', 0xc2}, map[string]any{"k": false}},
+	}
+	for _, tt := range tests {
+		got, err := NewMsgpackDecoder(bytes.NewReader(tt.in)).Decode()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMsgpackDecodeStream(t *testing.T) {
+	d := NewMsgpackDecoder(bytes.NewReader([]byte{0x01, 0xa1, 'x'}))
+	for _, want := range []any{int64(1), "x"} {
+		got, err := d.Decode()
+		if err != nil || got != want {
+			t.Fatalf("got %v, %v, want %v", got, err, want)
+		}
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Errorf("got %v at end of stream, want io.EOF", err)
+	}
+}
+
+func TestMsgpackDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want error // nil for any error
+	}{
+		{"truncated str", []byte{0xa3, 'a'}, io.ErrUnexpectedEOF},
+		{"truncated length", []byte{0xd9}, io.ErrUnexpectedEOF},
+		{"truncated uint32", []byte{0xce, 0x00, 0x01}, io.ErrUnexpectedEOF},
+		{"truncated map", []byte{0x82, 0xa1, 'a', 0x01}, io.ErrUnexpectedEOF},
+		{"map missing value", []byte{0x81, 0xa1, 'a'}, io.ErrUnexpectedEOF},
+		{"truncated ext", []byte{0xd5, 0x00, 0x01}, io.ErrUnexpectedEOF},
+		{"truncated bin32", []byte{0xc6, 0x00, 0x01, 0x00, 0x01, 'a'}, io.ErrUnexpectedEOF},
+		{"unknown type", []byte{0xc1}, nil},
+		{"length too large", []byte{0xdb, 0x04, 0x00, 0x00, 0x01}, nil},
+		{"array too large", []byte{0xdd, 0xff, 0xff, 0xff, 0xff}, nil},
+		{"too deep", bytes.Repeat([]byte{0x91}, maxMsgpackDepth+1), nil},
+	}
+	for _, tt := range tests {
+		got, err := NewMsgpackDecoder(bytes.NewReader(tt.in)).Decode()
+		if err == nil {
+			t.Errorf("%s: decoded %#v, want an error", tt.name, got)
+			continue
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// A short input claiming a large payload must fail without allocating it
+func TestMsgpackDecodeOversizedClaim(t *testing.T) {
+	in := []byte{0xc6, 0x02, 0x00, 0x00, 0x00, 'a'} // bin32 of 32MB
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := NewMsgpackDecoder(bytes.NewReader(in)).Decode()
+	runtime.ReadMemStats(&after)
+
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("allocated %d bytes for a %d byte input", allocated, len(in))
+	}
+}
+
+func TestAppendMsgpackString(t *testing.T) {
+	for _, n := range []int{0, 31, 32, 255, 256, 65535, 65536, 70000} {
+		s := strings.Repeat("x", n)
+		got, err := NewMsgpackDecoder(bytes.NewReader(AppendMsgpackString(nil, s))).Decode()
+		if err != nil {
+			t.Errorf("length %d: %v", n, err)
+			continue
+		}
+		if got != s {
+			t.Errorf("length %d: got %d bytes back", n, len(got.(string)))
+		}
+	}
+}
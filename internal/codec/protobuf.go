@@ -0,0 +1,88 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Protobuf wire types
+const (
+	WireVarint  = 0
+	WireFixed64 = 1
+	WireBytes   = 2
+	WireFixed32 = 5
+)
+
+// ProtoField is a single decoded field of a protobuf message. Scalars are
+// held in Uint (varint, fixed32 and fixed64 wire types) and length-delimited
+// values (strings, bytes, nested messages, packed repeats) in Bytes.
+type ProtoField struct {
+	Num   int
+	Type  int
+	Uint  uint64
+	Bytes []byte
+}
+
+// Int returns the field as a signed (non-zigzag) integer
+func (f ProtoField) Int() int64 {
+	return int64(f.Uint)
+}
+
+// Double returns a fixed64 field as a float64
+func (f ProtoField) Double() float64 {
+	return math.Float64frombits(f.Uint)
+}
+
+// String returns a length-delimited field as a string
+func (f ProtoField) String() string {
+	return string(f.Bytes)
+}
+
+// EachProtoField walks the top-level fields of a protobuf-encoded message
+// without a schema, calling fn for each one in wire order
+func EachProtoField(b []byte, fn func(f ProtoField) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return fmt.Errorf("protobuf: invalid field key")
+		}
+		b = b[n:]
+
+		f := ProtoField{Num: int(key >> 3), Type: int(key & 0x07)}
+		switch f.Type {
+		case WireVarint:
+			f.Uint, n = binary.Uvarint(b)
+			if n <= 0 {
+				return fmt.Errorf("protobuf: invalid varint in field %d", f.Num)
+			}
+			b = b[n:]
+		case WireFixed64:
+			if len(b) < 8 {
+				return fmt.Errorf("protobuf: truncated fixed64 in field %d", f.Num)
+			}
+			f.Uint = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case WireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return fmt.Errorf("protobuf: truncated bytes in field %d", f.Num)
+			}
+			f.Bytes = b[n : n+int(l)]
+			b = b[n+int(l):]
+		case WireFixed32:
+			if len(b) < 4 {
+				return fmt.Errorf("protobuf: truncated fixed32 in field %d", f.Num)
+			}
+			f.Uint = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		default:
+			return fmt.Errorf("protobuf: unsupported wire type %d in field %d", f.Type, f.Num)
+		}
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package codec
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEachProtoField(t *testing.T) {
+	in := []byte{
+		0x08, 0x96, 0x01, // 1: varint 150
+		0x12, 0x02, 'h', 'i', // 2: bytes "hi"
+		0x19, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f, // 3: double 1.5
+		0x25, 0x2a, 0x00, 0x00, 0x00, // 4: fixed32 42
+		0x28, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, // 5: varint -1
+	}
+	var got []ProtoField
+	err := EachProtoField(in, func(f ProtoField) error {
+		got = append(got, f)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 5 {
+		t.Fatalf("got %d fields, want 5", len(got))
+	}
+	for i, f := range got {
+		if f.Num != i+1 {
+			t.Errorf("field %d: got number %d", i, f.Num)
+		}
+	}
+	if got[0].Type != WireVarint || got[0].Uint != 150 {
+		t.Errorf("varint: got %+v", got[0])
+	}
+	if got[1].Type != WireBytes || got[1].String() != "hi" {
+		t.Errorf("bytes: got %+v", got[1])
+	}
+	if got[2].Type != WireFixed64 || got[2].Double() != 1.5 {
+		t.Errorf("fixed64: got %+v", got[2])
+	}
+	if got[3].Type != WireFixed32 || got[3].Uint != 42 {
+		t.Errorf("fixed32: got %+v", got[3])
+	}
+	if got[4].Int() != -1 {
+		t.Errorf("negative varint: got %d", got[4].Int())
+	}
+}
+
+func TestEachProtoFieldInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{"truncated key", []byte{0x80}},
+		{"truncated varint", []byte{0x08, 0xff}},
+		{"truncated bytes", []byte{0x12, 0x05, 'a'}},
+		{"missing length", []byte{0x12}},
+		{"oversized length", []byte{0x12, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 'a'}},
+		{"truncated fixed64", []byte{0x19, 0x00, 0x00, 0x00}},
+		{"truncated fixed32", []byte{0x25, 0x00}},
+		{"group wire type", []byte{0x0b}},
+	}
+	for _, tt := range tests {
+		err := EachProtoField(tt.in, func(ProtoField) error { return nil })
+		if err == nil {
+			t.Errorf("%s: want an error", tt.name)
+		}
+	}
+}
+
+func TestEachProtoFieldStops(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := EachProtoField([]byte{0x08, 0x01, 0x08, 0x02}, func(ProtoField) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("got %v after %d calls, want %v after 1", err, calls, stop)
+	}
+}
@@ -0,0 +1,95 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrCorrupt is returned when compressed input is malformed
+var ErrCorrupt = errors.New("codec: corrupt input")
+
+// maxSnappyDecodedLen bounds the size a snappy block may claim to expand to (256MB)
+const maxSnappyDecodedLen = 256 * 1024 * 1024
+
+// maxSnappyExpansion bounds how much a snappy block can expand: its densest
+// element, a 3-byte copy, writes 64 bytes
+const maxSnappyExpansion = 22
+
+// SnappyDecode decodes a snappy block (not the framed stream format), as used
+// by Prometheus remote write and the Loki push API
+func SnappyDecode(src []byte) ([]byte, error) {
+	n, hdr := binary.Uvarint(src)
+	// The claimed length is only trusted as far as the input can reach it
+	if hdr <= 0 || n > maxSnappyDecodedLen || n > uint64(len(src))*maxSnappyExpansion {
+		return nil, ErrCorrupt
+	}
+	src = src[hdr:]
+	dst := make([]byte, 0, n)
+
+	for len(src) > 0 {
+		tag := src[0]
+		var length, offset int
+
+		switch tag & 0x03 {
+		case 0x00: // literal
+			length = int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				extra := length - 59
+				if len(src) < extra {
+					return nil, ErrCorrupt
+				}
+				length = 0
+				for i := extra - 1; i >= 0; i-- {
+					length = length<<8 | int(src[i])
+				}
+				src = src[extra:]
+			}
+			length++
+			if length <= 0 || len(src) < length || uint64(len(dst)+length) > n {
+				return nil, ErrCorrupt
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+
+		case 0x01: // copy with 1-byte offset
+			if len(src) < 2 {
+				return nil, ErrCorrupt
+			}
+			length = 4 + int(tag>>2)&0x07
+			offset = int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+
+		case 0x02: // copy with 2-byte offset
+			if len(src) < 3 {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:3]))
+			src = src[3:]
+
+		case 0x03: // copy with 4-byte offset
+			if len(src) < 5 {
+				return nil, ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:5]))
+			src = src[5:]
+		}
+
+		if offset <= 0 || offset > len(dst) || uint64(len(dst)+length) > n {
+			return nil, ErrCorrupt
+		}
+		// Copies may overlap their own output, so copy byte by byte
+		start := len(dst) - offset
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[start+i])
+		}
+	}
+
+	if uint64(len(dst)) != n {
+		return nil, ErrCorrupt
+	}
+	return dst, nil
+}
@@ -0,0 +1,96 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSnappyDecode(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	tests := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{"empty", []byte{0x00}, ""},
+		{"literal", []byte{0x05, 0x10, 'h', 'e', 'l', 'l', 'o'}, "hello"},
+		{"long literal", append([]byte{100, 0xf0, 99}, long...), long},
+		{"copy 1-byte offset", []byte{0x08, 0x04, 'a', 'b', 0x09, 0x02}, "abababab"},
+		{"copy 2-byte offset", []byte{0x0c, 0x08, 'a', 'b', 'c', 0x22, 0x03, 0x00}, "abcabcabcabc"},
+		{"copy 4-byte offset", []byte{0x06, 0x08, 'a', 'b', 'c', 0x0b, 0x03, 0x00, 0x00, 0x00}, "abcabc"},
+	}
+	for _, tt := range tests {
+		got, err := SnappyDecode(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSnappyDecodeCorrupt(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{"no input", nil},
+		{"truncated length", []byte{0x80}},
+		{"truncated literal", []byte{0x05, 0x10, 'h', 'e'}},
+		{"truncated literal length", []byte{100, 0xf0}},
+		{"truncated copy", []byte{0x08, 0x04, 'a', 'b', 0x09}},
+		{"offset before start", []byte{0x08, 0x04, 'a', 'b', 0x09, 0x05}},
+		{"zero offset", []byte{0x08, 0x04, 'a', 'b', 0x09, 0x00}},
+		{"longer than claimed", []byte{0x03, 0x10, 'h', 'e', 'l', 'l', 'o'}},
+		{"shorter than claimed", []byte{0x06, 0x10, 'h', 'e', 'l', 'l', 'o'}},
+		{"copy past claimed length", []byte{0x04, 0x04, 'a', 'b', 0x09, 0x02}},
+		{"claim above limit", binary.AppendUvarint(nil, maxSnappyDecodedLen+1)},
+	}
+	for _, tt := range tests {
+		if got, err := SnappyDecode(tt.in); err == nil {
+			t.Errorf("%s: decoded %q, want an error", tt.name, got)
+		}
+	}
+}
+
+// A tiny body claiming a huge output must fail before allocating it
+func TestSnappyDecodeOversizedClaim(t *testing.T) {
+	in := binary.AppendUvarint(nil, maxSnappyDecodedLen)
+	in = append(in, 0x00, 'a')
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := SnappyDecode(in)
+	runtime.ReadMemStats(&after)
+
+	if err == nil {
+		t.Fatal("want an error")
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("allocated %d bytes for a %d byte input", allocated, len(in))
+	}
+}
+
+func TestSnappyDecodeMaxExpansion(t *testing.T) {
+	// A literal followed by 64-byte copies, the densest encoding
+	body := []byte{0x00, 'a'}
+	want := "a"
+	for range 100 {
+		body = append(body, 0xfe, 0x01, 0x00)
+		want += strings.Repeat("a", 64)
+	}
+	in := append(binary.AppendUvarint(nil, uint64(len(want))), body...)
+
+	got, err := SnappyDecode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, []byte(want)) {
+		t.Errorf("got %d bytes, want %d", len(got), len(want))
+	}
+}
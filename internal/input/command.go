@@ -0,0 +1,169 @@
+package input
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/lch88/logbro/internal/parser"
+)
+
+// Stream names used to tag entries produced by a wrapped command
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// commandDrainTimeout bounds how long output is read after the child exits,
+// as processes it started in the background may keep its pipes open
+const commandDrainTimeout = time.Second
+
+// Command runs a child process and feeds its stdout and stderr into a sink.
+// On Unix the child leads its own process group, so a terminal's Ctrl-C
+// reaches it once, through Signal, rather than alongside logbro.
+type Command struct {
+	cmd   *exec.Cmd
+	pipes []*os.File // read ends of stdout and stderr
+	wg    sync.WaitGroup
+	done  chan struct{}
+	code  int
+	err   error
+}
+
+// StartCommand spawns name with args, parsing every line it writes to
+// stdout and stderr and tagging the resulting entries with their stream
+func StartCommand(name string, args []string, p *parser.Parser, ml MultilineConfig, sink Sink) (*Command, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	setProcessGroup(cmd)
+
+	// Own pipes rather than cmd.StdoutPipe, so waiting for the child
+	// doesn't depend on reading them to EOF
+	stdout, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("stdout pipe: %w", err)
+	}
+	stderr, stderrW, err := os.Pipe()
+	if err != nil {
+		stdout.Close()
+		stdoutW.Close()
+		return nil, fmt.Errorf("stderr pipe: %w", err)
+	}
+	cmd.Stdout, cmd.Stderr = stdoutW, stderrW
+
+	err = cmd.Start()
+	// The child holds its own copies of the write ends
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		return nil, fmt.Errorf("start %s: %w", name, err)
+	}
+
+	c := &Command{
+		cmd:   cmd,
+		pipes: []*os.File{stdout, stderr},
+		done:  make(chan struct{}),
+	}
+
+	c.wg.Add(2)
+	go c.read(stdout, StreamStdout, p, ml, sink)
+	go c.read(stderr, StreamStderr, p, ml, sink)
+	go c.wait()
+
+	return c, nil
+}
+
+// String returns the command line being run
+func (c *Command) String() string {
+	return strings.Join(c.cmd.Args, " ")
+}
+
+// Signal forwards sig to the child and the processes it started
+func (c *Command) Signal(sig os.Signal) error {
+	return signalGroup(c.cmd.Process, sig)
+}
+
+// Kill stops the child and the processes it started right away
+func (c *Command) Kill() error {
+	return signalGroup(c.cmd.Process, os.Kill)
+}
+
+// Done is closed once the child has exited and the output it wrote has been
+// read
+func (c *Command) Done() <-chan struct{} {
+	return c.done
+}
+
+// ExitCode returns the child's exit status; only valid after Done is closed
+func (c *Command) ExitCode() int {
+	return c.code
+}
+
+// Err returns any error from waiting on the child other than a non-zero exit
+func (c *Command) Err() error {
+	return c.err
+}
+
+func (c *Command) read(r io.Reader, stream string, p *parser.Parser, ml MultilineConfig, sink Sink) {
+	defer c.wg.Done()
+
+	asm := NewAssembler(ml, func(lines []string) {
+		entry := p.ParseLines(lines)
+		entry.Stream = stream
+		sink(entry)
+	})
+	defer asm.Flush()
+
+	err := ReadLines(r, asm.Add)
+	if err != nil && !errors.Is(err, os.ErrClosed) {
+		log.Printf("Command %s read error: %v", stream, err)
+	}
+}
+
+func (c *Command) wait() {
+	err := c.cmd.Wait()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		c.err = err
+	}
+	c.code = exitCode(c.cmd.ProcessState)
+
+	// Read what is left in the pipes, but don't wait on background
+	// processes that inherited them
+	drained := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(commandDrainTimeout):
+	}
+	for _, pipe := range c.pipes {
+		pipe.Close()
+	}
+	close(c.done)
+}
+
+// exitCode mirrors shell conventions: 128+N when the child was killed by signal N
+func exitCode(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+	if code := state.ExitCode(); code >= 0 {
+		return code
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return -1
+}
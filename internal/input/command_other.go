@@ -0,0 +1,16 @@
+//go:build !unix
+
+package input
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op where process groups aren't supported
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalGroup sends sig to p alone
+func signalGroup(p *os.Process, sig os.Signal) error {
+	return p.Signal(sig)
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package input
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd in a new process group, away from the
+// terminal's foreground group. A background group is stopped when it reads
+// the terminal, so a terminal stdin is replaced with /dev/null.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if f, ok := cmd.Stdin.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			cmd.Stdin = nil
+		}
+	}
+}
+
+// signalGroup sends sig to the process group p leads
+func signalGroup(p *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return p.Signal(sig)
+	}
+	return syscall.Kill(-p.Pid, s)
+}
@@ -0,0 +1,270 @@
+package input
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lch88/logbro/internal/parser"
+)
+
+const (
+	// defaultPollInterval is how often tailed files are checked for new data
+	defaultPollInterval = 250 * time.Millisecond
+	// removedFileGrace is how long a file no pattern matches any more is
+	// still read, for writers that keep it open after a rotation
+	removedFileGrace = 30 * time.Second
+)
+
+// Tailer follows files matching a set of glob patterns like `tail -F`,
+// surviving renames, copytruncate rotation and files created later. Files
+// are tracked by identity rather than path, so a rotated file that still
+// matches a pattern is not read again under its new name.
+type Tailer struct {
+	patterns  []string
+	parser    *parser.Parser
+	multiline MultilineConfig
+	sink      Sink
+	interval  time.Duration
+	files     []*tailedFile
+	started   bool // the first poll has run
+}
+
+// tailedFile tracks one open file and the partial line read so far
+type tailedFile struct {
+	path     string // name the file was opened under, used as its source
+	file     *os.File
+	info     os.FileInfo
+	offset   int64
+	pending  []byte
+	asm      *Assembler
+	lastSeen time.Time // last poll a pattern matched the file
+}
+
+// NewTailer creates a tailer for the given file paths or glob patterns
+func NewTailer(patterns []string, p *parser.Parser, ml MultilineConfig, sink Sink) *Tailer {
+	return &Tailer{
+		patterns:  patterns,
+		parser:    p,
+		multiline: ml,
+		sink:      sink,
+		interval:  defaultPollInterval,
+	}
+}
+
+// Run polls the matched files until ctx is cancelled. Files present at
+// startup are followed from their end; files created later are read from
+// the beginning.
+func (t *Tailer) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		t.poll()
+
+		select {
+		case <-ctx.Done():
+			for _, tf := range t.files {
+				tf.close()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *Tailer) poll() {
+	now := time.Now()
+	for _, path := range t.match() {
+		info, err := os.Stat(path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Printf("File open error: %v", err)
+			}
+			continue
+		}
+		if tf := t.tracked(info); tf != nil {
+			tf.lastSeen = now
+			continue
+		}
+
+		tf, err := openTailed(path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Printf("File open error: %v", err)
+			}
+			continue
+		}
+		if !t.started {
+			if tf.offset, err = tf.file.Seek(0, io.SeekEnd); err != nil {
+				log.Printf("File seek error %s: %v", path, err)
+			}
+		}
+		tf.asm = t.newAssembler(path)
+		tf.lastSeen = now
+		t.files = append(t.files, tf)
+	}
+	t.started = true
+
+	files := t.files[:0]
+	for _, tf := range t.files {
+		t.follow(tf, now)
+		if tf.file != nil {
+			files = append(files, tf)
+		}
+	}
+	clear(t.files[len(files):])
+	t.files = files
+}
+
+// tracked returns the open file that is the same file as info, if any
+func (t *Tailer) tracked(info os.FileInfo) *tailedFile {
+	for _, tf := range t.files {
+		if os.SameFile(tf.info, info) {
+			return tf
+		}
+	}
+	return nil
+}
+
+// match expands all patterns into a sorted, de-duplicated list of paths.
+// Patterns without glob characters are kept even if the file does not exist
+// yet, so it is picked up once created.
+func (t *Tailer) match() []string {
+	seen := make(map[string]bool)
+	for _, pattern := range t.patterns {
+		if !hasGlobMeta(pattern) {
+			seen[pattern] = true
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Printf("Invalid file pattern %q: %v", pattern, err)
+			continue
+		}
+		for _, m := range matches {
+			seen[m] = true
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for p := range seen {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// follow reads new data from tf and reacts to truncation or removal
+func (t *Tailer) follow(tf *tailedFile, now time.Time) {
+	t.read(tf)
+
+	if tf.lastSeen.Before(now) {
+		// Removed, or rotated to a name no pattern matches: a replacement
+		// is tracked on its own, and the old file is drained for a while
+		// in case its writer still has it open
+		if now.Sub(tf.lastSeen) >= removedFileGrace {
+			t.flush(tf)
+			tf.close()
+		}
+		return
+	}
+
+	current, err := tf.file.Stat()
+	if err == nil && current.Size() < tf.offset {
+		// Truncated in place (copytruncate)
+		t.flush(tf)
+		if _, err := tf.file.Seek(0, io.SeekStart); err == nil {
+			tf.offset = 0
+			t.read(tf)
+		}
+	}
+}
+
+// read consumes all data currently available and emits complete lines
+func (t *Tailer) read(tf *tailedFile) {
+	if tf.file == nil {
+		return
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := tf.file.Read(buf)
+		if n > 0 {
+			tf.offset += int64(n)
+			tf.pending = append(tf.pending, buf[:n]...)
+			t.emitLines(tf)
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("File read error %s: %v", tf.path, err)
+			}
+			return
+		}
+	}
+}
+
+func (t *Tailer) emitLines(tf *tailedFile) {
+	for {
+		i := bytes.IndexByte(tf.pending, '\n')
+		if i < 0 {
+			if len(tf.pending) >= maxScanTokenSize {
+				tf.asm.Add(string(tf.pending))
+				tf.pending = tf.pending[:0]
+			}
+			return
+		}
+		tf.asm.Add(strings.TrimSuffix(string(tf.pending[:i]), "\r"))
+		tf.pending = tf.pending[i+1:]
+	}
+}
+
+// flush emits a trailing line that was never newline-terminated along with
+// any event still being assembled
+func (t *Tailer) flush(tf *tailedFile) {
+	if len(tf.pending) > 0 {
+		tf.asm.Add(strings.TrimSuffix(string(tf.pending), "\r"))
+		tf.pending = nil
+	}
+	tf.asm.Flush()
+}
+
+// newAssembler groups lines of one file into events tagged with its path
+func (t *Tailer) newAssembler(path string) *Assembler {
+	return NewAssembler(t.multiline, func(lines []string) {
+		entry := t.parser.ParseLinesFrom(path, lines)
+		parser.SetSource(&entry, path)
+		t.sink(entry)
+	})
+}
+
+func openTailed(path string) (*tailedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &tailedFile{path: path, file: f, info: info}, nil
+}
+
+func (tf *tailedFile) close() {
+	if tf.file != nil {
+		tf.file.Close()
+		tf.file = nil
+	}
+}
+
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[`)
+}
@@ -0,0 +1,215 @@
+package input
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/lch88/logbro/internal/codec"
+	"github.com/lch88/logbro/internal/parser"
+)
+
+// StartForward binds Fluent Forward protocol listeners described by addrs,
+// a comma-separated list such as "tcp://:24224", and feeds received events
+// into sink until ctx is cancelled. Shared-key authentication is not supported.
+func StartForward(ctx context.Context, addrs string, p *parser.Parser, sink Sink) error {
+	return startListeners(ctx, "Forward", addrs, nil,
+		func(conn net.Conn) { handleForwardConn(conn, p, sink) },
+	)
+}
+
+// limitedReader fails once more than n bytes are read, unlike io.LimitReader
+// which would end a truncated chunk silently
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if l.n -= int64(n); l.n < 0 {
+		return 0, fmt.Errorf("decompressed chunk exceeds %d bytes", codec.MaxMsgpackBytes)
+	}
+	return n, err
+}
+
+// forwardEvent is a single decoded event of any forward mode
+type forwardEvent struct {
+	time   time.Time
+	record map[string]any
+}
+
+func handleForwardConn(conn net.Conn, p *parser.Parser, sink Sink) {
+	dec := codec.NewMsgpackDecoder(conn)
+	for {
+		msg, err := dec.Decode()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Forward read error from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		tag, events, option, err := decodeForwardMessage(msg)
+		if err != nil {
+			log.Printf("Forward decode error from %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+
+		for _, ev := range events {
+			entry := p.ParseRecord(ev.record)
+			if entry.Parsed.Time == nil && !ev.time.IsZero() {
+				t := ev.time
+				entry.Parsed.Time = &t
+			}
+			if tag != "" {
+				parser.SetSource(&entry, tag)
+			}
+			sink(entry)
+		}
+
+		// Clients that request at-least-once delivery wait for an ack
+		if chunk, ok := option["chunk"].(string); ok && chunk != "" {
+			ack := []byte{0x81} // fixmap with one entry
+			ack = codec.AppendMsgpackString(ack, "ack")
+			ack = codec.AppendMsgpackString(ack, chunk)
+			if _, err := conn.Write(ack); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// decodeForwardMessage handles the Message, Forward, PackedForward and
+// CompressedPackedForward modes
+func decodeForwardMessage(msg any) (string, []forwardEvent, map[string]any, error) {
+	arr, ok := msg.([]any)
+	if !ok || len(arr) < 2 {
+		return "", nil, nil, fmt.Errorf("expected [tag, ...] array")
+	}
+	tag := msgpackString(arr[0])
+
+	switch payload := arr[1].(type) {
+	case []any:
+		// Forward: [tag, [[time, record], ...], option]
+		option := optionAt(arr, 2)
+		events, err := decodeForwardEntries(payload)
+		return tag, events, option, err
+
+	case string, []byte:
+		// PackedForward: [tag, concatenated msgpack entries, option]
+		option := optionAt(arr, 2)
+		var r io.Reader = bytes.NewReader([]byte(msgpackString(payload)))
+		if option["compressed"] == "gzip" {
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				return tag, nil, option, err
+			}
+			defer zr.Close()
+			// Bound the decompressed chunk like any other message
+			r = &limitedReader{r: zr, n: codec.MaxMsgpackBytes}
+		}
+
+		var events []forwardEvent
+		dec := codec.NewMsgpackDecoder(r)
+		for {
+			v, err := dec.Decode()
+			if errors.Is(err, io.EOF) {
+				return tag, events, option, nil
+			}
+			if err != nil {
+				return tag, events, option, err
+			}
+			entry, _ := v.([]any)
+			ev, err := decodeForwardEntry(entry)
+			if err != nil {
+				return tag, events, option, err
+			}
+			events = append(events, ev)
+		}
+
+	default:
+		// Message: [tag, time, record, option]
+		if len(arr) < 3 {
+			return tag, nil, nil, fmt.Errorf("message mode needs [tag, time, record]")
+		}
+		ev, err := decodeForwardEntry(arr[1:3])
+		return tag, []forwardEvent{ev}, optionAt(arr, 3), err
+	}
+}
+
+func decodeForwardEntries(entries []any) ([]forwardEvent, error) {
+	events := make([]forwardEvent, 0, len(entries))
+	for _, e := range entries {
+		entry, _ := e.([]any)
+		ev, err := decodeForwardEntry(entry)
+		if err != nil {
+			return events, err
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+// decodeForwardEntry decodes [time, record]; newer Fluent Bit versions send
+// [[time, metadata], record]
+func decodeForwardEntry(entry []any) (forwardEvent, error) {
+	if len(entry) < 2 {
+		return forwardEvent{}, fmt.Errorf("expected [time, record] entry")
+	}
+	record, ok := entry[1].(map[string]any)
+	if !ok {
+		return forwardEvent{}, fmt.Errorf("record is not a map")
+	}
+
+	ts := entry[0]
+	if withMeta, ok := ts.([]any); ok && len(withMeta) > 0 {
+		ts = withMeta[0]
+	}
+	return forwardEvent{time: forwardTime(ts), record: record}, nil
+}
+
+// forwardTime converts integer/float seconds or an EventTime extension
+func forwardTime(v any) time.Time {
+	switch t := v.(type) {
+	case int64:
+		return time.Unix(t, 0)
+	case uint64:
+		return time.Unix(int64(t), 0)
+	case float64:
+		return time.Unix(0, int64(t*1e9))
+	case codec.MsgpackExt:
+		if t.Type == 0 && len(t.Data) == 8 {
+			sec := binary.BigEndian.Uint32(t.Data[:4])
+			nsec := binary.BigEndian.Uint32(t.Data[4:])
+			return time.Unix(int64(sec), int64(nsec))
+		}
+	}
+	return time.Time{}
+}
+
+func optionAt(arr []any, i int) map[string]any {
+	if i < len(arr) {
+		if m, ok := arr[i].(map[string]any); ok {
+			return m
+		}
+	}
+	return nil
+}
+
+func msgpackString(v any) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	}
+	return ""
+}
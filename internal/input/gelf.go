@@ -0,0 +1,222 @@
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lch88/logbro/internal/parser"
+)
+
+const (
+	// maxGELFChunks is the most chunks a single message may be split into
+	maxGELFChunks = 128
+	// gelfChunkTimeout discards incomplete chunked messages (per the GELF spec)
+	gelfChunkTimeout = 5 * time.Second
+	// maxGELFMessageSize bounds a decompressed message or TCP frame
+	maxGELFMessageSize = 8 * 1024 * 1024
+	// maxGELFPending bounds the chunked messages reassembled at once; chunks
+	// of further messages are dropped until some complete or expire
+	maxGELFPending = 1024
+	// maxGELFPendingBytes bounds the chunk data held for them
+	maxGELFPendingBytes = 64 * 1024 * 1024
+)
+
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// StartGELF binds the listeners described by addrs, a comma-separated list
+// such as "udp://:12201,tcp://:12201", and feeds received GELF messages into
+// sink until ctx is cancelled
+func StartGELF(ctx context.Context, addrs string, p *parser.Parser, sink Sink) error {
+	return startListeners(ctx, "GELF", addrs,
+		func(conn net.PacketConn) { serveGELFUDP(ctx, conn, p, sink) },
+		func(conn net.Conn) { handleGELFConn(conn, p, sink) },
+	)
+}
+
+// gelfChunks collects the chunks of one message until all have arrived
+type gelfChunks struct {
+	parts    [][]byte
+	received int
+	size     int
+	first    time.Time
+}
+
+// gelfAssembler reassembles chunked UDP messages keyed by message ID
+type gelfAssembler struct {
+	mu       sync.Mutex
+	messages map[[8]byte]*gelfChunks
+	size     int // chunk bytes held across messages
+}
+
+func serveGELFUDP(ctx context.Context, conn net.PacketConn, p *parser.Parser, sink Sink) {
+	asm := &gelfAssembler{messages: make(map[[8]byte]*gelfChunks)}
+	go asm.expire(ctx)
+
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("GELF UDP read error: %v", err)
+			}
+			return
+		}
+
+		datagram := buf[:n]
+		if bytes.HasPrefix(datagram, gelfChunkMagic) {
+			datagram = asm.add(datagram)
+			if datagram == nil {
+				continue
+			}
+		} else {
+			datagram = bytes.Clone(datagram)
+		}
+
+		handleGELFPayload(datagram, p, sink)
+	}
+}
+
+// add stores a chunk and returns the complete message once every chunk has
+// been received
+func (a *gelfAssembler) add(chunk []byte) []byte {
+	// magic(2) + message id(8) + sequence number(1) + sequence count(1)
+	if len(chunk) < 12 {
+		return nil
+	}
+	var id [8]byte
+	copy(id[:], chunk[2:10])
+	seq, count := int(chunk[10]), int(chunk[11])
+	if count == 0 || count > maxGELFChunks || seq >= count {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	data := chunk[12:]
+	if a.size+len(data) > maxGELFPendingBytes {
+		return nil
+	}
+	msg, ok := a.messages[id]
+	if !ok {
+		if len(a.messages) >= maxGELFPending {
+			return nil
+		}
+		msg = &gelfChunks{parts: make([][]byte, count), first: time.Now()}
+		a.messages[id] = msg
+	}
+	if len(msg.parts) != count || msg.parts[seq] != nil {
+		return nil
+	}
+	msg.parts[seq] = bytes.Clone(data)
+	msg.received++
+	msg.size += len(data)
+	a.size += len(data)
+
+	if msg.received < count {
+		return nil
+	}
+	a.remove(id, msg)
+	return bytes.Join(msg.parts, nil)
+}
+
+func (a *gelfAssembler) remove(id [8]byte, msg *gelfChunks) {
+	delete(a.messages, id)
+	a.size -= msg.size
+}
+
+// expire drops chunked messages that never completed
+func (a *gelfAssembler) expire(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			for id, msg := range a.messages {
+				if time.Since(msg.first) > gelfChunkTimeout {
+					a.remove(id, msg)
+				}
+			}
+			a.mu.Unlock()
+		}
+	}
+}
+
+// handleGELFConn reads null-byte delimited, uncompressed messages
+func handleGELFConn(conn net.Conn, p *parser.Parser, sink Sink) {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), maxGELFMessageSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+
+	for scanner.Scan() {
+		if frame := bytes.TrimSpace(scanner.Bytes()); len(frame) > 0 {
+			handleGELFPayload(frame, p, sink)
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Printf("GELF TCP read error from %s: %v", conn.RemoteAddr(), err)
+	}
+}
+
+func handleGELFPayload(data []byte, p *parser.Parser, sink Sink) {
+	payload, err := decompressGELF(data)
+	if err != nil {
+		log.Printf("GELF decode error: %v", err)
+		return
+	}
+	entry, err := p.ParseGELF(payload)
+	if err != nil {
+		log.Printf("GELF decode error: %v", err)
+		return
+	}
+	sink(entry)
+}
+
+// decompressGELF detects zlib or gzip compression from the magic bytes
+func decompressGELF(data []byte) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch {
+	case len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b:
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	case len(data) >= 2 && data[0] == 0x78:
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	out, err := io.ReadAll(io.LimitReader(r, maxGELFMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxGELFMessageSize {
+		return nil, fmt.Errorf("message exceeds %d bytes", maxGELFMessageSize)
+	}
+	return out, nil
+}
@@ -0,0 +1,28 @@
+package input
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+// Sink receives parsed log entries for storage and broadcasting
+type Sink func(entry models.LogEntry)
+
+// maxScanTokenSize bounds a single log line (1MB)
+const maxScanTokenSize = 1024 * 1024
+
+// ReadLines calls fn for every line read from r until EOF or a read error
+func ReadLines(r io.Reader, fn func(line string)) error {
+	scanner := bufio.NewScanner(r)
+	// Increase buffer size for long log lines
+	scanBuf := make([]byte, maxScanTokenSize)
+	scanner.Buffer(scanBuf, maxScanTokenSize)
+
+	for scanner.Scan() {
+		fn(scanner.Text())
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,87 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// startListeners binds every address in addrs, a comma-separated list such
+// as "udp://:5514,tcp://:5514", and serves it until ctx is cancelled.
+// Either handler may be nil when the protocol does not support that transport.
+func startListeners(ctx context.Context, name, addrs string, serveUDP func(net.PacketConn), serveTCP func(net.Conn)) error {
+	var closers []io.Closer
+	closeAll := func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}
+
+	for _, addr := range strings.Split(addrs, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+
+		u, err := url.Parse(addr)
+		if err != nil || u.Host == "" {
+			closeAll()
+			return fmt.Errorf("invalid %s address %q: want udp://host:port or tcp://host:port", name, addr)
+		}
+
+		switch {
+		case u.Scheme == "udp" && serveUDP != nil:
+			conn, err := net.ListenPacket("udp", u.Host)
+			if err != nil {
+				closeAll()
+				return fmt.Errorf("%s listen %s: %w", name, addr, err)
+			}
+			closers = append(closers, conn)
+			go serveUDP(conn)
+
+		case u.Scheme == "tcp" && serveTCP != nil:
+			ln, err := net.Listen("tcp", u.Host)
+			if err != nil {
+				closeAll()
+				return fmt.Errorf("%s listen %s: %w", name, addr, err)
+			}
+			closers = append(closers, ln)
+			go acceptLoop(name, ln, serveTCP)
+
+		default:
+			closeAll()
+			return fmt.Errorf("unsupported %s protocol %q in %q", name, u.Scheme, addr)
+		}
+
+		log.Printf("%s listening on %s", name, addr)
+	}
+
+	go func() {
+		<-ctx.Done()
+		closeAll()
+	}()
+
+	return nil
+}
+
+// acceptLoop hands each accepted connection to serve on its own goroutine
+func acceptLoop(name string, ln net.Listener, serve func(net.Conn)) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("%s TCP accept error: %v", name, err)
+			}
+			return
+		}
+		go func() {
+			defer conn.Close()
+			serve(conn)
+		}()
+	}
+}
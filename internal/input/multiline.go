@@ -0,0 +1,275 @@
+package input
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lch88/logbro/internal/ansi"
+	"github.com/lch88/logbro/internal/parser"
+)
+
+// DefaultMultilineTimeout is how long an incomplete event waits for more lines
+const DefaultMultilineTimeout = 250 * time.Millisecond
+
+// maxMultilineLines bounds a single assembled event
+const maxMultilineLines = 1000
+
+// MultilineConfig controls how consecutive lines are grouped into one event
+type MultilineConfig struct {
+	// Enabled turns on assembly; when false every line is its own event
+	Enabled bool
+	// StartPatterns, when set, mark the first line of an event; every line
+	// not matching one of them continues the previous event. The built-in
+	// rules are used when empty.
+	StartPatterns []*regexp.Regexp
+	// Timeout flushes a pending event when no further line arrives
+	Timeout time.Duration
+}
+
+// Built-in continuation rules for common stack trace formats
+var (
+	// Java: "\tat com.x.Y(Y.java:1)", "Caused by: ...", "... 12 more"
+	javaContinuationPattern = regexp.MustCompile(`^(at |Caused by:|Suppressed:|\.\.\. \d+ (more|common frames omitted))`)
+	// Java: "java.lang.IllegalStateException: msg" printed after the log line
+	javaExceptionPattern = regexp.MustCompile(`^([\w$]+\.)+[\w$]*(Exception|Error|Throwable)(:|$)`)
+	// Go: "panic: ..." / "fatal error: ..." start a dump containing goroutine headers
+	goPanicStartPattern = regexp.MustCompile(`^(panic: |fatal error: )`)
+	goroutinePattern    = regexp.MustCompile(`^goroutine \d+ \[`)
+	goFramePattern      = regexp.MustCompile(`^(created by |\[signal |exit status \d+$|[\w./*()\[\]{}-]+\(.*\)$)`)
+	// Python: "Traceback (most recent call last):"
+	pythonTracebackPattern = regexp.MustCompile(`^Traceback \(most recent call last\):`)
+	pythonChainPattern     = regexp.MustCompile(`^(During handling of the above exception|The above exception was the direct cause)`)
+)
+
+// traceMode tracks which multi-line format is being assembled
+type traceMode int
+
+const (
+	modeNone traceMode = iota
+	modeGo
+	modePython
+)
+
+// Assembler groups consecutive lines that belong to one event, such as a
+// stack trace following its log line, and passes each event to emit.
+// emit is called without the lock held, one event at a time in order.
+type Assembler struct {
+	cfg  MultilineConfig
+	emit func(lines []string)
+
+	emitMu  sync.Mutex // serializes emit calls
+	mu      sync.Mutex
+	ready   [][]string // completed events waiting for emit
+	pending []string
+	prefix  string // envelope key (Compose service, stream, ...) of the pending event
+	mode    traceMode
+	timer   *time.Timer
+
+	// CRI partial lines waiting for their final part, by stream
+	criPartials map[string]*criPartial
+}
+
+// criPartial accumulates a line a CRI runtime split into P parts
+type criPartial struct {
+	timestamp string
+	content   strings.Builder
+}
+
+// NewAssembler creates an assembler; with assembly disabled, every line is
+// emitted immediately
+func NewAssembler(cfg MultilineConfig, emit func(lines []string)) *Assembler {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultMultilineTimeout
+	}
+	return &Assembler{cfg: cfg, emit: emit}
+}
+
+// LineEmitter returns an emit function for NewAssembler that parses each
+// event with p and hands the entry to sink
+func LineEmitter(p *parser.Parser, sink Sink) func(lines []string) {
+	return func(lines []string) {
+		sink(p.ParseLines(lines))
+	}
+}
+
+// Add feeds the next line
+func (a *Assembler) Add(line string) {
+	line, ok := a.joinCRI(line)
+	if !ok {
+		return
+	}
+
+	if !a.cfg.Enabled {
+		a.emit([]string{line})
+		return
+	}
+
+	a.mu.Lock()
+	a.addLocked(line)
+	a.mu.Unlock()
+	a.emitReady()
+}
+
+func (a *Assembler) addLocked(line string) {
+	prefix, content := "", line
+	if key, c, ok := parser.SplitEnvelope(line); ok {
+		prefix, content = key, c
+	}
+	content = ansi.Strip(content) // colored traces still group
+
+	if len(a.pending) > 0 && prefix == a.prefix && len(a.pending) < maxMultilineLines && a.continues(content) {
+		a.pending = append(a.pending, line)
+	} else {
+		a.flushLocked()
+		a.pending = append(a.pending, line)
+		a.prefix = prefix
+		a.mode = modeNone
+		if a.cfg.StartPatterns == nil {
+			a.startMode(content)
+		}
+	}
+
+	if a.timer == nil {
+		a.timer = time.AfterFunc(a.cfg.Timeout, a.Flush)
+	} else {
+		a.timer.Reset(a.cfg.Timeout)
+	}
+}
+
+// joinCRI reassembles lines a CRI runtime split into partial (P) parts. It
+// reports false while the final (F) part is still missing.
+func (a *Assembler) joinCRI(line string) (string, bool) {
+	timestamp, stream, partial, content, ok := parser.SplitCRI(line)
+	if !ok {
+		return line, true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	buf := a.criPartials[stream]
+	if partial {
+		if buf == nil {
+			if a.criPartials == nil {
+				a.criPartials = make(map[string]*criPartial)
+			}
+			buf = &criPartial{timestamp: timestamp}
+			a.criPartials[stream] = buf
+		}
+		buf.content.WriteString(content)
+		if buf.content.Len() < maxScanTokenSize {
+			return "", false
+		}
+		// Give up on runaway lines rather than buffering without bound
+	} else if buf == nil {
+		return line, true
+	} else {
+		buf.content.WriteString(content)
+	}
+
+	delete(a.criPartials, stream)
+	return parser.JoinCRI(buf.timestamp, stream, buf.content.String()), true
+}
+
+// Flush emits any pending event, including CRI lines whose final part
+// never arrived
+func (a *Assembler) Flush() {
+	a.mu.Lock()
+	partials := a.criPartials
+	a.criPartials = nil
+	a.mu.Unlock()
+	for stream, buf := range partials {
+		a.Add(parser.JoinCRI(buf.timestamp, stream, buf.content.String()))
+	}
+
+	a.mu.Lock()
+	a.flushLocked()
+	a.mu.Unlock()
+	a.emitReady()
+}
+
+// flushLocked queues the pending event for emitReady
+func (a *Assembler) flushLocked() {
+	if len(a.pending) == 0 {
+		return
+	}
+	a.ready = append(a.ready, a.pending)
+	a.pending = nil
+	a.mode = modeNone
+}
+
+// emitReady passes queued events to emit. Callers must not hold mu, so a
+// slow emit doesn't block lines arriving meanwhile.
+func (a *Assembler) emitReady() {
+	a.emitMu.Lock()
+	defer a.emitMu.Unlock()
+	for {
+		a.mu.Lock()
+		events := a.ready
+		a.ready = nil
+		a.mu.Unlock()
+		if len(events) == 0 {
+			return
+		}
+		for _, lines := range events {
+			a.emit(lines)
+		}
+	}
+}
+
+// continues reports whether content belongs to the pending event
+func (a *Assembler) continues(content string) bool {
+	if a.cfg.StartPatterns != nil {
+		for _, re := range a.cfg.StartPatterns {
+			if re.MatchString(content) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch a.mode {
+	case modeGo:
+		// Goroutine dumps mix blank lines, frames and tab-indented file paths
+		return content == "" || isIndented(content) ||
+			goroutinePattern.MatchString(content) || goFramePattern.MatchString(content)
+
+	case modePython:
+		if content == "" || isIndented(content) || pythonChainPattern.MatchString(content) ||
+			pythonTracebackPattern.MatchString(content) {
+			return true
+		}
+		// The exception line ("ValueError: ...") closes the traceback
+		a.mode = modeNone
+		return true
+	}
+
+	switch {
+	case isIndented(content), javaContinuationPattern.MatchString(content), javaExceptionPattern.MatchString(content):
+		return true
+	case pythonTracebackPattern.MatchString(content):
+		// logger.exception() prints the message, then the traceback
+		a.mode = modePython
+		return true
+	case goroutinePattern.MatchString(content):
+		a.mode = modeGo
+		return true
+	}
+	return false
+}
+
+// startMode enters a trace mode when the first line of an event opens one
+func (a *Assembler) startMode(content string) {
+	switch {
+	case goPanicStartPattern.MatchString(content), goroutinePattern.MatchString(content):
+		a.mode = modeGo
+	case pythonTracebackPattern.MatchString(content):
+		a.mode = modePython
+	}
+}
+
+func isIndented(s string) bool {
+	return strings.HasPrefix(s, " ") || strings.HasPrefix(s, "\t")
+}
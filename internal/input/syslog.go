@@ -0,0 +1,94 @@
+package input
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/lch88/logbro/internal/parser"
+)
+
+// maxSyslogMessageSize bounds a single UDP datagram or framed TCP message
+const maxSyslogMessageSize = 64 * 1024
+
+// StartSyslog binds the listeners described by addrs, a comma-separated list
+// such as "udp://:5514,tcp://:5514", and feeds received messages into sink
+// until ctx is cancelled
+func StartSyslog(ctx context.Context, addrs string, p *parser.Parser, sink Sink) error {
+	return startListeners(ctx, "Syslog", addrs,
+		func(conn net.PacketConn) { serveSyslogUDP(conn, p, sink) },
+		func(conn net.Conn) { handleSyslogConn(conn, p, sink) },
+	)
+}
+
+func serveSyslogUDP(conn net.PacketConn, p *parser.Parser, sink Sink) {
+	buf := make([]byte, maxSyslogMessageSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Syslog UDP read error: %v", err)
+			}
+			return
+		}
+		if msg := strings.TrimRight(string(buf[:n]), "\r\n\x00"); msg != "" {
+			sink(p.ParseSyslog(msg))
+		}
+	}
+}
+
+// handleSyslogConn reads RFC 6587 framed messages, detecting octet-counting
+// ("LEN SP MSG") or newline-delimited framing per message
+func handleSyslogConn(conn net.Conn, p *parser.Parser, sink Sink) {
+	r := bufio.NewReaderSize(conn, maxSyslogMessageSize)
+	for {
+		msg, err := readSyslogFrame(r)
+		if msg != "" {
+			sink(p.ParseSyslog(msg))
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Syslog TCP read error from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+func readSyslogFrame(r *bufio.Reader) (string, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return "", err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		// Octet counting
+		lenStr, err := r.ReadString(' ')
+		if err != nil {
+			return "", err
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(lenStr, " "))
+		if err != nil || n > maxSyslogMessageSize {
+			return "", fmt.Errorf("invalid octet count %q", lenStr)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(buf), "\r\n\x00"), nil
+	}
+
+	// Non-transparent framing, terminated by LF
+	line, err := r.ReadString('\n')
+	line = strings.TrimRight(line, "\r\n\x00")
+	if err != nil && line != "" && errors.Is(err, io.EOF) {
+		return line, nil
+	}
+	return line, err
+}
@@ -4,28 +4,67 @@ import "time"
 
 // LogEntry represents a single log line
 type LogEntry struct {
-	ID        uint64     `json:"id"`
-	Timestamp time.Time  `json:"timestamp"`
-	Raw       string     `json:"raw"`
-	Parsed    *ParsedLog `json:"parsed,omitempty"`
+	ID        uint64      `json:"id"`
+	Timestamp time.Time   `json:"timestamp"`
+	Raw       string      `json:"raw"`
+	Stream    string      `json:"stream,omitempty"` // stdout/stderr when captured from a wrapped command
+	Styles    []StyleSpan `json:"styles,omitempty"` // ANSI colors of Raw, which has the escapes removed
+	Parsed    *ParsedLog  `json:"parsed,omitempty"`
+}
+
+// StyleSpan styles Raw[Start:End] (byte offsets) as the ANSI escape codes
+// of the original line did. Colors are one of the 16 standard names
+// ("red", "bright-blue", ...) or "#rrggbb".
+type StyleSpan struct {
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+	FG        string `json:"fg,omitempty"`
+	BG        string `json:"bg,omitempty"`
+	Bold      bool   `json:"bold,omitempty"`
+	Dim       bool   `json:"dim,omitempty"`
+	Italic    bool   `json:"italic,omitempty"`
+	Underline bool   `json:"underline,omitempty"`
+	Strike    bool   `json:"strike,omitempty"`
+	Inverse   bool   `json:"inverse,omitempty"`
+	Link      string `json:"link,omitempty"` // OSC 8 hyperlink target
 }
 
 // ParsedLog contains extracted fields from structured logs
 type ParsedLog struct {
-	Time    *time.Time     `json:"time,omitempty"`
-	Level   string         `json:"level,omitempty"`
-	Message string         `json:"message,omitempty"`
-	Source  string         `json:"source,omitempty"`
-	Fields  map[string]any `json:"fields,omitempty"`
+	Time         *time.Time     `json:"time,omitempty"`
+	Level        string         `json:"level,omitempty"`
+	Message      string         `json:"message,omitempty"`
+	Source       string         `json:"source,omitempty"`
+	TraceID      string         `json:"traceId,omitempty"`
+	SpanID       string         `json:"spanId,omitempty"`
+	ParentSpanID string         `json:"parentSpanId,omitempty"`
+	RequestID    string         `json:"requestId,omitempty"`
+	Fields       map[string]any `json:"fields,omitempty"`
 }
 
 // LogFilter for querying logs
 type LogFilter struct {
-	Search  string   `json:"search,omitempty"`
-	Levels  []string `json:"levels,omitempty"`
-	Regex   bool     `json:"regex,omitempty"`
-	AfterID uint64   `json:"afterId,omitempty"`
-	Limit   int      `json:"limit,omitempty"`
+	Search   string   `json:"search,omitempty"`
+	Query    string   `json:"query,omitempty"` // structured query, see package query
+	Levels   []string `json:"levels,omitempty"`
+	MinLevel string   `json:"minLevel,omitempty"` // matches this level and anything more severe
+	Sources  []string `json:"sources,omitempty"`
+	Regex    bool     `json:"regex,omitempty"`
+	AfterID  uint64   `json:"afterId,omitempty"`
+	Limit    int      `json:"limit,omitempty"`
+
+	Since     string `json:"since,omitempty"`     // time, or duration ago such as 15m
+	Until     string `json:"until,omitempty"`     // time, or duration ago; exclusive
+	TimeField string `json:"timeField,omitempty"` // time Since/Until bound: "arrival" (default) or "log"
+	Order     string `json:"order,omitempty"`     // result order: "arrival" (default) or "log"
+}
+
+// TimeRange bounds entries by arrival or log time, including Since and
+// excluding Until; zero ends are open
+type TimeRange struct {
+	Since   time.Time
+	Until   time.Time
+	LogTime bool // bound the parsed log time, falling back to arrival
 }
 
 // LogResponse is the REST API response for log queries
@@ -35,13 +74,54 @@ type LogResponse struct {
 	HasMore bool       `json:"hasMore"`
 }
 
+// TraceResponse is the /api/traces/{id} response
+type TraceResponse struct {
+	ID    string       `json:"id"`
+	Logs  []LogEntry   `json:"logs"`  // ordered by log time
+	Spans []*TraceSpan `json:"spans"` // spans without a known parent
+}
+
+// TraceSpan groups the entries of one span, nested under its parent span
+type TraceSpan struct {
+	SpanID       string       `json:"spanId"`
+	ParentSpanID string       `json:"parentSpanId,omitempty"`
+	Sources      []string     `json:"sources,omitempty"`
+	LogIDs       []uint64     `json:"logIds"`
+	Children     []*TraceSpan `json:"children,omitempty"`
+}
+
 // StatusResponse for /api/status endpoint
 type StatusResponse struct {
-	BufferSize    int    `json:"bufferSize"`
-	BufferUsed    int    `json:"bufferUsed"`
-	TotalReceived uint64 `json:"totalReceived"`
-	Uptime        string `json:"uptime"`
-	StdinOpen     bool   `json:"stdinOpen"`
+	BufferSize     int         `json:"bufferSize"` // 0 when only bounded by bytes
+	BufferUsed     int         `json:"bufferUsed"`
+	BufferBytes    int64       `json:"bufferBytes"` // estimated memory held by buffered entries
+	BufferMaxBytes int64       `json:"bufferMaxBytes,omitempty"`
+	Evictions      uint64      `json:"evictions"` // entries dropped to stay within the limits
+	TotalReceived  uint64      `json:"totalReceived"`
+	Uptime         string      `json:"uptime"`
+	StdinOpen      bool        `json:"stdinOpen"`
+	Command        string      `json:"command,omitempty"`
+	ExitCode       *int        `json:"exitCode,omitempty"`
+	Levels         []LevelInfo `json:"levels"`
+	Store          *StoreStats `json:"store,omitempty"`
+	// Redactions counts redacted values per detector when redaction is on
+	Redactions map[string]uint64 `json:"redactions,omitempty"`
+}
+
+// StoreStats describes the on-disk log store
+type StoreStats struct {
+	Dir      string `json:"dir"`
+	Segments int    `json:"segments"`
+	Bytes    int64  `json:"bytes"`
+	MaxBytes int64  `json:"maxBytes,omitempty"`
+	FirstID  uint64 `json:"firstId,omitempty"` // oldest stored entry
+	LastID   uint64 `json:"lastId,omitempty"`
+}
+
+// LevelInfo describes a log level and its severity ordering
+type LevelInfo struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
 }
 
 // WSMessage represents WebSocket messages sent from server to client
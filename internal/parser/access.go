@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+// clfTimeLayout is the bracketed Common Log Format time, e.g. [10/Oct/2000:13:55:36 -0700]
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// Common Log Format, optionally extended to Combined Log Format with referer
+// and user agent, and an nginx-style trailing request time:
+//
+//	127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326 "http://ref/" "Mozilla/4.08" 0.012
+var accessLogPattern = regexp.MustCompile(
+	`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\d+|-)` +
+		`(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?` +
+		`(?: (\d+(?:\.\d+)?))?\s*$`)
+
+// parseAccessLog parses Apache/nginx CLF and Combined Log Format lines
+func (p *Parser) parseAccessLog(line string) *models.ParsedLog {
+	m := accessLogPattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+
+	status, _ := strconv.Atoi(m[6])
+	fields := map[string]any{
+		"remote_addr": m[1],
+		"status":      status,
+	}
+	if m[3] != "-" {
+		fields["remote_user"] = m[3]
+	}
+	if m[7] != "-" {
+		bytes, _ := strconv.Atoi(m[7])
+		fields["bytes"] = bytes
+	}
+
+	// Request line: METHOD PATH PROTOCOL (may be "-" or garbage for bad requests)
+	request := unescapeAccessField(m[5])
+	if parts := strings.Fields(request); len(parts) == 3 {
+		fields["method"] = parts[0]
+		fields["path"] = parts[1]
+		fields["protocol"] = parts[2]
+	} else if request != "-" {
+		fields["request"] = request
+	}
+
+	if referer := unescapeAccessField(m[8]); referer != "" && referer != "-" {
+		fields["referer"] = referer
+	}
+	if ua := unescapeAccessField(m[9]); ua != "" && ua != "-" {
+		fields["user_agent"] = ua
+	}
+	if m[10] != "" {
+		if rt, err := strconv.ParseFloat(m[10], 64); err == nil {
+			fields["request_time"] = rt
+		}
+	}
+
+	parsed := &models.ParsedLog{
+		Level:   accessLogLevel(status),
+		Message: request + " " + m[6],
+		Fields:  fields,
+	}
+	if t, err := time.Parse(clfTimeLayout, m[4]); err == nil {
+		parsed.Time = &t
+	}
+	return parsed
+}
+
+// accessLogLevel derives a level from the HTTP status class
+func accessLogLevel(status int) string {
+	switch {
+	case status >= 500:
+		return "ERROR"
+	case status >= 400:
+		return "WARN"
+	default:
+		return "INFO"
+	}
+}
+
+// unescapeAccessField undoes the \" and \\ escaping servers apply inside
+// quoted fields
+func unescapeAccessField(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s)
+}
@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Container runtime line formats wrapping the application's own output
+var (
+	// CRI (containerd, CRI-O) node log files: "2024-01-01T00:00:00.1Z stdout F msg",
+	// where P marks a line the runtime split and F its final part
+	criPattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2})) (stdout|stderr) ([FP])(?: (.*))?$`)
+	// kubectl logs --prefix: "[pod/name/container] msg"
+	kubectlPrefixPattern = regexp.MustCompile(`^\[pod/([^/\]]+)/([^\]]+)\] ?(.*)$`)
+)
+
+// envelope is the metadata a runtime or tool wrapped around a line
+type envelope struct {
+	// key identifies the stream of lines the envelope belongs to, for
+	// format caching and multiline grouping
+	key string
+	// source replaces the parsed source when set
+	source string
+	stream string
+	time   *time.Time
+	fields map[string]any
+	// stripRaw drops the envelope from Raw, for machine formats
+	stripRaw bool
+}
+
+// unwrapEnvelope recognises Docker Compose prefixes, kubectl --prefix, CRI
+// and Docker json-file lines and returns the payload they wrap
+func unwrapEnvelope(line string) (envelope, string, bool) {
+	if service, content, ok := SplitDockerPrefix(line); ok {
+		return envelope{key: service, source: service}, content, true
+	}
+
+	if m := kubectlPrefixPattern.FindStringSubmatch(line); m != nil {
+		pod, container := m[1], m[2]
+		return envelope{
+			key:    pod + "/" + container,
+			source: pod + "/" + container,
+			fields: map[string]any{"pod": pod, "container": container},
+		}, m[3], true
+	}
+
+	if m := criPattern.FindStringSubmatch(line); m != nil {
+		env := envelope{key: m[2], stream: m[2], stripRaw: true}
+		if t, err := time.Parse(time.RFC3339Nano, m[1]); err == nil {
+			env.time = &t
+		}
+		return env, m[4], true
+	}
+
+	if strings.HasPrefix(line, `{"log":`) {
+		var rec struct {
+			Log    *string `json:"log"`
+			Stream string  `json:"stream"`
+			Time   string  `json:"time"`
+		}
+		if err := json.Unmarshal([]byte(line), &rec); err == nil && rec.Log != nil && rec.Stream != "" {
+			env := envelope{key: rec.Stream, stream: rec.Stream, stripRaw: true}
+			if t, err := time.Parse(time.RFC3339Nano, rec.Time); err == nil {
+				env.time = &t
+			}
+			return env, strings.TrimRight(*rec.Log, "\r\n"), true
+		}
+	}
+
+	return envelope{}, "", false
+}
+
+// SplitEnvelope splits a line wrapped by Docker Compose, kubectl, a CRI
+// runtime or Docker's json-file driver into a key identifying its stream
+// (service, pod/container or stdout/stderr) and the wrapped content
+func SplitEnvelope(line string) (key, content string, ok bool) {
+	env, content, ok := unwrapEnvelope(line)
+	return env.key, content, ok
+}
+
+// SplitCRI splits a CRI log line into its timestamp, stream, partial flag
+// and content
+func SplitCRI(line string) (timestamp, stream string, partial bool, content string, ok bool) {
+	m := criPattern.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false, "", false
+	}
+	return m[1], m[2], m[3] == "P", m[4], true
+}
+
+// JoinCRI builds the CRI line for a payload reassembled from partial lines
+func JoinCRI(timestamp, stream, content string) string {
+	return timestamp + " " + stream + " F " + content
+}
@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+// ParseGELF converts a decompressed GELF (Graylog Extended Log Format) JSON
+// payload into a log entry
+func (p *Parser) ParseGELF(data []byte) (models.LogEntry, error) {
+	msg, err := decodeObject(data)
+	if err != nil {
+		return models.LogEntry{}, fmt.Errorf("invalid GELF message: %w", err)
+	}
+
+	shortMessage, _ := msg["short_message"].(string)
+	parsed := &models.ParsedLog{
+		Fields: make(map[string]any),
+	}
+
+	if level, ok := msg["level"].(float64); ok {
+		parsed.Level = SyslogSeverityLevel(int(level))
+	}
+	if ts, ok := msg["timestamp"].(float64); ok && ts > 0 {
+		sec, frac := math.Modf(ts)
+		t := time.Unix(int64(sec), int64(frac*1e9))
+		parsed.Time = &t
+	}
+	if host, ok := msg["host"].(string); ok && host != "" {
+		parsed.Source = host
+		parsed.Fields["host"] = host
+	}
+	if full, ok := msg["full_message"].(string); ok && full != "" {
+		parsed.Fields["full_message"] = full
+	}
+	for _, key := range []string{"facility", "file", "line"} {
+		if v, ok := msg[key]; ok {
+			parsed.Fields[key] = v
+		}
+	}
+
+	// Additional fields are prefixed with an underscore
+	for k, v := range msg {
+		if strings.HasPrefix(k, "_") && k != "_id" {
+			parsed.Fields[k[1:]] = v
+		}
+	}
+
+	// Docker's gelf driver identifies the container, which is more useful
+	// than the host when several services share a machine
+	if name, ok := parsed.Fields["container_name"].(string); ok && name != "" {
+		parsed.Source = strings.TrimPrefix(name, "/")
+	}
+
+	p.applyBody(shortMessage, parsed)
+	ExtractTrace(parsed)
+	if len(parsed.Fields) == 0 {
+		parsed.Fields = nil
+	}
+
+	return models.LogEntry{
+		Timestamp: time.Now(),
+		Raw:       shortMessage,
+		Parsed:    parsed,
+	}, nil
+}
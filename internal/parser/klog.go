@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+// Format names for Go logging libraries
+const (
+	FormatKlog  = "klog"
+	FormatGoLog = "golog"
+)
+
+// klog/glog header: Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg
+var klogPattern = regexp.MustCompile(`^([IWEF])(\d{4} \d{2}:\d{2}:\d{2}(?:\.\d+)?)\s+(\d+) ([^\s:\]]+):(\d+)\] ?(.*)$`)
+
+// Go standard library log with LstdFlags, optionally Lmicroseconds and
+// Lshortfile/Llongfile: "2006/01/02 15:04:05.000000 file.go:12: msg"
+var goLogPattern = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?) (?:(\S+\.go:\d+): )?(.*)$`)
+
+var klogSeverityLevels = map[string]string{
+	"I": "INFO",
+	"W": "WARN",
+	"E": "ERROR",
+	"F": "FATAL",
+}
+
+// parseKlog handles Kubernetes-style klog/glog lines, including structured
+// logging's `"msg" key="value"` payloads
+func (p *Parser) parseKlog(line string) *models.ParsedLog {
+	m := klogPattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+
+	parsed := &models.ParsedLog{
+		Level:   klogSeverityLevels[m[1]],
+		Source:  m[4] + ":" + m[5],
+		Message: m[6],
+		Fields:  make(map[string]any),
+	}
+	if t, err := time.ParseInLocation("0102 15:04:05", m[2], p.loc()); err == nil {
+		t = completeYear(t)
+		parsed.Time = &t
+	}
+	if tid, err := strconv.Atoi(m[3]); err == nil {
+		parsed.Fields["thread"] = tid
+	}
+
+	// Structured logging: a quoted message followed by key=value pairs
+	msg := m[6]
+	if strings.HasPrefix(msg, `"`) {
+		if end := closingQuote(msg, 0); end > 0 {
+			if unquoted, err := strconv.Unquote(msg[:end+1]); err == nil {
+				rest := strings.TrimSpace(msg[end+1:])
+				if rest == "" {
+					parsed.Message = unquoted
+				} else if pairs, ok := decodeLogfmtPairs(rest, 1); ok {
+					parsed.Message = unquoted
+					for k, v := range pairs {
+						parsed.Fields[k] = v
+					}
+				}
+			}
+		}
+	}
+	return parsed
+}
+
+// parseGoLog handles lines written by the standard library's log package
+func (p *Parser) parseGoLog(line string) *models.ParsedLog {
+	m := goLogPattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+
+	parsed := &models.ParsedLog{
+		Level:   matchLevel(m[3]),
+		Source:  m[2],
+		Message: m[3],
+	}
+	if t, err := time.ParseInLocation("2006/01/02 15:04:05", m[1], p.loc()); err == nil {
+		parsed.Time = &t
+	}
+	return parsed
+}
@@ -0,0 +1,282 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+// Numeric level schemes selectable with SetLevelScheme
+const (
+	LevelSchemeAuto   = "auto"   // pick by value range
+	LevelSchemePino   = "pino"   // pino/bunyan: 10 trace ... 60 fatal
+	LevelSchemePython = "python" // Python logging: 10 debug ... 50 critical
+	LevelSchemeSyslog = "syslog" // syslog severity: 0 emerg ... 7 debug
+	LevelSchemeOTel   = "otel"   // OpenTelemetry SeverityNumber 1-24
+	LevelSchemeLog4j  = "log4j"  // log4j 2 intLevel: 100 fatal ... 600 trace
+)
+
+// LevelSchemes lists the names accepted by SetLevelScheme
+var LevelSchemes = []string{
+	LevelSchemeAuto, LevelSchemePino, LevelSchemePython, LevelSchemeSyslog, LevelSchemeOTel, LevelSchemeLog4j,
+}
+
+// levelsMu guards the level tables, which DefineLevel and AliasLevel extend
+var levelsMu sync.RWMutex
+
+// Level priority for ordering (higher = more severe). Spaced out like pino's
+// numeric levels so user-defined levels can slot in between.
+var levelPriority = map[string]int{
+	"TRACE": 10,
+	"DEBUG": 20,
+	"INFO":  30,
+	"WARN":  40,
+	"ERROR": 50,
+	"FATAL": 60,
+}
+
+// Level names used by common libraries (zap, log4j, java.util.logging,
+// Python, syslog) mapped to the normalized levels
+var levelAliases = map[string]string{
+	"TRC":         "TRACE",
+	"FINEST":      "TRACE",
+	"FINER":       "TRACE",
+	"DBG":         "DEBUG",
+	"FINE":        "DEBUG",
+	"INF":         "INFO",
+	"INFORMATION": "INFO",
+	"NOTICE":      "INFO",
+	"CONFIG":      "INFO",
+	"WRN":         "WARN",
+	"WARNING":     "WARN",
+	"ERR":         "ERROR",
+	"SEVERE":      "ERROR",
+	"CRIT":        "FATAL",
+	"CRITICAL":    "FATAL",
+	"PANIC":       "FATAL",
+	"DPANIC":      "FATAL",
+	"ALERT":       "FATAL",
+	"EMERG":       "FATAL",
+	"EMERGENCY":   "FATAL",
+}
+
+// Common log level patterns
+var levelPatterns = map[string]*regexp.Regexp{
+	"TRACE": regexp.MustCompile(`(?i)\b(TRACE|TRC)\b`),
+	"DEBUG": regexp.MustCompile(`(?i)\b(DEBUG|DBG)\b`),
+	"INFO":  regexp.MustCompile(`(?i)\b(INFO|INF)\b`),
+	"WARN":  regexp.MustCompile(`(?i)\b(WARN|WARNING|WRN)\b`),
+	"ERROR": regexp.MustCompile(`(?i)\b(ERROR|ERR)\b`),
+	"FATAL": regexp.MustCompile(`(?i)\b(FATAL|CRITICAL|CRIT|PANIC)\b`),
+}
+
+// DefineLevel adds a level name with its priority relative to the built-in
+// levels (TRACE 10, DEBUG 20, INFO 30, WARN 40, ERROR 50, FATAL 60), or
+// changes the priority of an existing one. Plain text lines containing the
+// name are recognised as that level.
+func DefineLevel(name string, priority int) error {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if name == "" {
+		return fmt.Errorf("empty level name")
+	}
+
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	levelPriority[name] = priority
+	delete(levelAliases, name)
+	if _, exists := levelPatterns[name]; !exists {
+		levelPatterns[name] = regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(name) + `\b`)
+	}
+	return nil
+}
+
+// AliasLevel makes NormalizeLevel map alias to level, which must be a
+// built-in or defined level
+func AliasLevel(alias, level string) error {
+	alias = strings.ToUpper(strings.TrimSpace(alias))
+	level = strings.ToUpper(strings.TrimSpace(level))
+
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	if _, ok := levelPriority[level]; !ok {
+		return fmt.Errorf("unknown level %q", level)
+	}
+	levelAliases[alias] = level
+	return nil
+}
+
+// LevelPriority returns the ordering of a normalized level, or 0 when the
+// level is unknown
+func LevelPriority(level string) int {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	return levelPriority[strings.ToUpper(level)]
+}
+
+// LevelAtLeast reports whether level is as severe as minLevel. Entries
+// with an unknown level never match.
+func LevelAtLeast(level, minLevel string) bool {
+	priority := LevelPriority(level)
+	return priority > 0 && priority >= LevelPriority(minLevel)
+}
+
+// Levels returns every known level ordered by priority
+func Levels() []models.LevelInfo {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+
+	levels := make([]models.LevelInfo, 0, len(levelPriority))
+	for name, priority := range levelPriority {
+		levels = append(levels, models.LevelInfo{Name: name, Priority: priority})
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if levels[i].Priority != levels[j].Priority {
+			return levels[i].Priority < levels[j].Priority
+		}
+		return levels[i].Name < levels[j].Name
+	})
+	return levels
+}
+
+// NormalizeLevel maps level names and common abbreviations to TRACE,
+// DEBUG, INFO, WARN, ERROR, FATAL or a user-defined level. Unknown names
+// are returned upper-cased.
+func NormalizeLevel(level string) string {
+	level = strings.ToUpper(strings.TrimSpace(level))
+
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	if alias, ok := levelAliases[level]; ok {
+		return alias
+	}
+	return level
+}
+
+// matchLevel finds the most severe level named in a plain text line
+func matchLevel(line string) string {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+
+	level := ""
+	highestPriority := 0
+	for name, pattern := range levelPatterns {
+		if priority := levelPriority[name]; priority > highestPriority && pattern.MatchString(line) {
+			highestPriority = priority
+			level = name
+		}
+	}
+	return level
+}
+
+// SetLevelScheme selects how numeric level values in structured records are
+// interpreted
+func (p *Parser) SetLevelScheme(scheme string) error {
+	for _, s := range LevelSchemes {
+		if s == scheme {
+			p.mu.Lock()
+			p.levelScheme = scheme
+			p.mu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown level scheme %q (available: %s)", scheme, strings.Join(LevelSchemes, ", "))
+}
+
+// numericLevel maps a numeric level using the configured scheme
+func (p *Parser) numericLevel(n int) string {
+	p.mu.RLock()
+	scheme := p.levelScheme
+	p.mu.RUnlock()
+	return NumericLevel(n, scheme)
+}
+
+// NumericLevel maps a numeric level value to a normalized level under the
+// given scheme, or "" when the value is out of range. The auto scheme
+// recognises pino and log4j steps and otherwise treats 0-7 as syslog and
+// 8-24 as OpenTelemetry.
+func NumericLevel(n int, scheme string) string {
+	switch scheme {
+	case LevelSchemePino:
+		return steppedLevel(n, 10, []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"})
+	case LevelSchemePython:
+		if n > 0 && n < 10 {
+			return "TRACE"
+		}
+		return steppedLevel(n, 10, []string{"DEBUG", "INFO", "WARN", "ERROR", "FATAL"})
+	case LevelSchemeLog4j:
+		return steppedLevel(n, 100, []string{"FATAL", "ERROR", "WARN", "INFO", "DEBUG", "TRACE"})
+	case LevelSchemeSyslog:
+		return SyslogSeverityLevel(n)
+	case LevelSchemeOTel:
+		return OTelSeverityLevel(n)
+	}
+
+	switch {
+	case n >= 10 && n <= 60 && n%10 == 0:
+		return NumericLevel(n, LevelSchemePino)
+	case n >= 100 && n <= 600 && n%100 == 0:
+		return NumericLevel(n, LevelSchemeLog4j)
+	case n >= 0 && n <= 7:
+		return SyslogSeverityLevel(n)
+	case n <= 24:
+		return OTelSeverityLevel(n)
+	}
+	return ""
+}
+
+// steppedLevel maps values in steps of size to names; values between steps
+// round up, as libraries place custom levels between the standard ones
+func steppedLevel(n, step int, names []string) string {
+	if n <= 0 {
+		return ""
+	}
+	i := (n + step - 1) / step
+	if i > len(names) {
+		i = len(names)
+	}
+	return names[i-1]
+}
+
+// OTelSeverityLevel maps an OpenTelemetry SeverityNumber (1-24) to a
+// normalized level, or "" when unspecified
+func OTelSeverityLevel(number int) string {
+	switch {
+	case number <= 0:
+		return ""
+	case number <= 4:
+		return "TRACE"
+	case number <= 8:
+		return "DEBUG"
+	case number <= 12:
+		return "INFO"
+	case number <= 16:
+		return "WARN"
+	case number <= 20:
+		return "ERROR"
+	default:
+		return "FATAL"
+	}
+}
+
+// levelValue converts a structured level field, which may be a name, a
+// number or a number in a string, to a normalized level. It reports false
+// for values that map to no level, such as out-of-range numbers.
+func (p *Parser) levelValue(v any) (string, bool) {
+	var level string
+	switch lv := v.(type) {
+	case string:
+		if n, err := strconv.Atoi(strings.TrimSpace(lv)); err == nil {
+			level = p.numericLevel(n)
+		} else {
+			level = NormalizeLevel(lv)
+		}
+	case float64, int64, uint64:
+		level = p.numericLevel(int(toFloat(lv)))
+	}
+	return level, level != ""
+}
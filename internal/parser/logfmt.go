@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+// minLogfmtPairs is how many key=value pairs a line needs to count as logfmt
+const minLogfmtPairs = 2
+
+// parseLogfmt handles lines like `time=... level=info msg="started" port=8080`
+// (Go kit, Heroku, Grafana, slog TextHandler)
+func (p *Parser) parseLogfmt(line string) *models.ParsedLog {
+	data, ok := decodeLogfmt(strings.TrimSpace(line))
+	if !ok {
+		return nil
+	}
+	return p.parseFields(data)
+}
+
+// decodeLogfmt splits a logfmt line into its key/value pairs. It reports
+// false unless the line starts with a key=value pair and contains at least
+// minLogfmtPairs of them, so ordinary prose is left to parseText.
+func decodeLogfmt(line string) (map[string]any, bool) {
+	return decodeLogfmtPairs(line, minLogfmtPairs)
+}
+
+// decodeLogfmtPairs is decodeLogfmt with a custom minimum number of pairs
+func decodeLogfmtPairs(line string, minPairs int) (map[string]any, bool) {
+	data := make(map[string]any)
+	pairs := 0
+
+	for i := 0; i < len(line); {
+		// Skip separating whitespace
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i >= len(line) {
+			break
+		}
+
+		// Key runs until '=' or whitespace
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '\t' {
+			if line[i] == '"' {
+				return nil, false
+			}
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			return nil, false
+		}
+
+		if i >= len(line) || line[i] != '=' {
+			// Bare key, e.g. "debug" in "level=info debug"
+			if pairs == 0 {
+				return nil, false
+			}
+			data[key] = true
+			continue
+		}
+		i++ // skip '='
+
+		var value string
+		if i < len(line) && line[i] == '"' {
+			end := closingQuote(line, i)
+			if end < 0 {
+				return nil, false
+			}
+			unquoted, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				// Tolerate escapes Go doesn't know by keeping the text as-is
+				unquoted = line[i+1 : end]
+			}
+			value = unquoted
+			i = end + 1
+		} else {
+			start := i
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				i++
+			}
+			value = line[start:i]
+		}
+
+		data[key] = value
+		pairs++
+	}
+
+	return data, pairs >= minPairs
+}
+
+// closingQuote returns the index of the quote ending the string opened at
+// line[open], skipping backslash escapes, or -1 if it is unterminated
+func closingQuote(line string, open int) int {
+	for i := open + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
@@ -1,93 +1,196 @@
 package parser
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/lch88/logbro/internal/ansi"
 	"github.com/lch88/logbro/internal/models"
 )
 
-// Common log level patterns
-var levelPatterns = map[string]*regexp.Regexp{
-	"DEBUG": regexp.MustCompile(`(?i)\b(DEBUG|DBG)\b`),
-	"INFO":  regexp.MustCompile(`(?i)\b(INFO|INF)\b`),
-	"WARN":  regexp.MustCompile(`(?i)\b(WARN|WARNING|WRN)\b`),
-	"ERROR": regexp.MustCompile(`(?i)\b(ERROR|ERR)\b`),
-	"FATAL": regexp.MustCompile(`(?i)\b(FATAL|CRITICAL|CRIT|PANIC)\b`),
-}
-
 // Docker compose log format: "service-name  | actual log content"
-var dockerComposePattern = regexp.MustCompile(`^([a-zA-Z0-9_-]+(?:-\d+)?)\s+\|\s*(.*)$`)
-
-// ANSI escape code pattern
-var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
-
-// Level priority for ordering (higher = more severe)
-var levelPriority = map[string]int{
-	"DEBUG": 1,
-	"INFO":  2,
-	"WARN":  3,
-	"ERROR": 4,
-	"FATAL": 5,
-}
+var dockerComposePattern = regexp.MustCompile(`^([a-zA-Z0-9_-]+(?:-\d+)?)\s+\| ?(.*)$`)
 
-// Common timestamp patterns
-var timestampPatterns = []struct {
-	pattern *regexp.Regexp
-	layout  string
-}{
-	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})?`), time.RFC3339},
-	{regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(\.\d+)?`), "2006-01-02 15:04:05"},
-	{regexp.MustCompile(`\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2}`), "02/Jan/2006:15:04:05"},
-	{regexp.MustCompile(`\w{3} +\d{1,2} \d{2}:\d{2}:\d{2}`), "Jan  2 15:04:05"},
-}
+// Parser handles log line parsing. It is safe for concurrent use.
+type Parser struct {
+	mu        sync.RWMutex
+	formats   []*format
+	forced    string            // format used for every source, "" to auto-detect
+	overrides map[string]string // source -> format name
+	detected  map[string]string // source -> format detected from earlier lines
+
+	levelScheme string // how numeric levels are read, see SetLevelScheme
 
-// Parser handles log line parsing
-type Parser struct{}
+	location          *time.Location                // zone for timestamps without one
+	timeLayouts       []timestampPattern            // custom layouts for every source
+	sourceTimeLayouts map[string][]timestampPattern // custom layouts per source
+}
 
 // New creates a new parser instance
 func New() *Parser {
-	return &Parser{}
+	p := &Parser{
+		overrides: make(map[string]string),
+		detected:  make(map[string]string),
+
+		levelScheme: LevelSchemeAuto,
+
+		location:          time.UTC,
+		sourceTimeLayouts: make(map[string][]timestampPattern),
+	}
+	p.registerBuiltins()
+	return p
 }
 
 // Parse analyzes a log line and extracts metadata
 func (p *Parser) Parse(line string) models.LogEntry {
-	entry := models.LogEntry{
-		Timestamp: time.Now(),
-		Raw:       line,
+	return p.ParseFrom("", line)
+}
+
+// ParseFrom parses a line received from source, such as a tailed file path.
+// The source selects the format override and detection cache to use; a
+// Docker Compose or kubectl prefix on the line takes precedence over it.
+//
+// Envelopes added by Docker Compose, kubectl, CRI runtimes and Docker's
+// json-file driver are unwrapped and the payload parsed in turn.
+//
+// ANSI escape codes are removed from Raw and kept as its Styles.
+func (p *Parser) ParseFrom(source, line string) models.LogEntry {
+	env, content, ok := unwrapEnvelope(line)
+	if !ok {
+		raw, styles := ansi.Parse(line)
+		parsed := p.decode(source, raw)
+		parsed.Message = ansi.Strip(parsed.Message)
+		return models.LogEntry{
+			Timestamp: time.Now(),
+			Raw:       raw,
+			Styles:    styles,
+			Parsed:    parsed,
+		}
 	}
 
-	// Check for Docker Compose format first
-	// Strip ANSI codes before matching
-	cleaned := ansiPattern.ReplaceAllString(line, "")
-	if match := dockerComposePattern.FindStringSubmatch(cleaned); match != nil {
-		source := match[1]
-		content := match[2]
+	if env.source != "" {
+		source = env.source
+		content = strings.TrimLeft(content, " \t\r\n\f")
+	}
+	entry := p.ParseFrom(source, content)
+	if !env.stripRaw {
+		entry.Raw, entry.Styles = ansi.Parse(line)
+	}
 
-		// Try to parse the content as JSON
-		if parsed := p.parseJSON(content); parsed != nil {
-			parsed.Source = source // Docker source takes precedence
-			entry.Parsed = parsed
-			return entry
+	parsed := entry.Parsed
+	if env.source != "" {
+		parsed.Source = env.source // Prefix source takes precedence
+	}
+	if entry.Stream == "" {
+		entry.Stream = env.stream
+	}
+	if parsed.Time == nil {
+		parsed.Time = env.time
+	}
+	for k, v := range env.fields {
+		if parsed.Fields == nil {
+			parsed.Fields = make(map[string]any)
 		}
+		if _, exists := parsed.Fields[k]; !exists {
+			parsed.Fields[k] = v
+		}
+	}
+	return entry
+}
+
+// ParseLines parses a multiline event such as a stack trace. Metadata is
+// taken from the first line while Raw and Message keep every line.
+func (p *Parser) ParseLines(lines []string) models.LogEntry {
+	return p.ParseLinesFrom("", lines)
+}
 
-		// Try text parsing on the content
-		parsed := p.parseText(content)
-		parsed.Source = source
-		entry.Parsed = parsed
+// ParseLinesFrom is ParseLines for lines received from source
+func (p *Parser) ParseLinesFrom(source string, lines []string) models.LogEntry {
+	entry := p.ParseFrom(source, lines[0])
+	if len(lines) == 1 {
 		return entry
 	}
 
-	// Try JSON first
-	if parsed := p.parseJSON(line); parsed != nil {
-		entry.Parsed = parsed
-		return entry
+	// Continuation lines repeat any envelope; Docker Compose and kubectl
+	// prefixes are kept in Raw only, runtime envelopes are dropped
+	rest := make([]string, len(lines)-1)
+	var raw strings.Builder
+	raw.WriteString(entry.Raw)
+	for i, line := range lines[1:] {
+		rawLine := line
+		if env, content, ok := unwrapEnvelope(line); ok {
+			line = content
+			if env.stripRaw {
+				rawLine = content
+			}
+		}
+		rest[i] = ansi.Strip(line)
+
+		plain, styles := ansi.Parse(rawLine)
+		raw.WriteByte('\n')
+		for _, span := range styles {
+			span.Start += raw.Len()
+			span.End += raw.Len()
+			entry.Styles = append(entry.Styles, span)
+		}
+		raw.WriteString(plain)
+	}
+	tail := strings.Join(rest, "\n")
+
+	entry.Raw = raw.String()
+	if entry.Parsed.Message != "" {
+		entry.Parsed.Message += "\n" + tail
+	} else {
+		entry.Parsed.Message = tail
+	}
+	if entry.Parsed.Level == "" {
+		entry.Parsed.Level = p.parseText(tail).Level
+	}
+	return entry
+}
+
+// SplitDockerPrefix splits a Docker Compose "service | content" line,
+// ignoring ANSI color codes around the prefix. Indentation of the content
+// after the separator is preserved.
+func SplitDockerPrefix(line string) (source, content string, ok bool) {
+	cleaned := ansi.Strip(line)
+	match := dockerComposePattern.FindStringSubmatch(cleaned)
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], match[2], true
+}
+
+// ParseRecord builds an entry from an already-decoded structured record,
+// such as a Fluent Forward event, using the same key extraction as JSON lines
+func (p *Parser) ParseRecord(record map[string]any) models.LogEntry {
+	entry := models.LogEntry{
+		Timestamp: time.Now(),
+	}
+
+	// Shippers wrapping plain lines (Docker's fluentd driver, tail inputs)
+	// put the original line under "log"
+	if line, ok := record["log"].(string); ok {
+		entry.Raw, entry.Styles = ansi.Parse(strings.TrimRight(line, "\r\n"))
+	} else {
+		raw, _ := json.Marshal(record)
+		entry.Raw = string(raw)
 	}
 
-	// Fall back to pattern matching
-	entry.Parsed = p.parseText(line)
+	parsed := p.parseFields(record)
+	parsed.Message = ansi.Strip(parsed.Message)
+	if strings.HasPrefix(strings.TrimSpace(parsed.Message), "{") {
+		p.applyBody(parsed.Message, parsed)
+	}
+	ExtractTrace(parsed)
+	entry.Parsed = parsed
 	return entry
 }
 
@@ -97,20 +200,72 @@ func (p *Parser) parseJSON(line string) *models.ParsedLog {
 		return nil
 	}
 
-	var data map[string]any
-	if err := json.Unmarshal([]byte(line), &data); err != nil {
+	data, err := decodeObject([]byte(line))
+	if err != nil {
 		return nil
 	}
 
+	return p.parseFields(data)
+}
+
+// maxExactInt is the largest integer a float64 holds exactly
+const maxExactInt = 1 << 53
+
+// decodeObject decodes a JSON object. Numbers become float64, except
+// integers too large for one to hold exactly, such as Datadog's 64-bit ids
+// or epoch nanoseconds, which become int64 or uint64.
+func decodeObject(data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var obj map[string]any
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid data after top-level value")
+	}
+	convertNumbers(obj)
+	return obj, nil
+}
+
+// convertNumbers replaces the json.Number values in v
+func convertNumbers(v any) any {
+	switch tv := v.(type) {
+	case json.Number:
+		s := tv.String()
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			if n > maxExactInt || n < -maxExactInt {
+				return n
+			}
+		} else if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return n
+		}
+		f, _ := tv.Float64()
+		return f
+	case map[string]any:
+		for k, item := range tv {
+			tv[k] = convertNumbers(item)
+		}
+	case []any:
+		for i, item := range tv {
+			tv[i] = convertNumbers(item)
+		}
+	}
+	return v
+}
+
+// parseFields extracts level, message, time and source from a decoded
+// structured record; the remaining keys become Fields
+func (p *Parser) parseFields(data map[string]any) *models.ParsedLog {
 	parsed := &models.ParsedLog{
 		Fields: make(map[string]any),
 	}
 
 	// Extract known fields (level)
-	for _, key := range []string{"level", "lvl", "severity", "log.level"} {
+	for _, key := range []string{"level", "lvl", "severity", "log.level", "levelname"} {
 		if v, ok := data[key]; ok {
-			if s, ok := v.(string); ok {
-				parsed.Level = normalizeLevel(s)
+			if level, ok := p.levelValue(v); ok {
+				parsed.Level = level
 				delete(data, key)
 				break
 			}
@@ -128,29 +283,13 @@ func (p *Parser) parseJSON(line string) *models.ParsedLog {
 		}
 	}
 
-	// Extract timestamp
-	for _, key := range []string{"time", "timestamp", "ts", "@timestamp", "datetime"} {
+	// Extract timestamp; values that can't be read stay in Fields
+	for _, key := range []string{"time", "timestamp", "ts", "@timestamp", "datetime", "asctime"} {
 		if v, ok := data[key]; ok {
-			switch tv := v.(type) {
-			case string:
-				if t, err := time.Parse(time.RFC3339, tv); err == nil {
-					parsed.Time = &t
-				} else if t, err := time.Parse(time.RFC3339Nano, tv); err == nil {
-					parsed.Time = &t
-				}
-			case float64:
-				// Unix timestamp (seconds or milliseconds)
-				if tv > 1e12 {
-					// Milliseconds
-					t := time.UnixMilli(int64(tv))
-					parsed.Time = &t
-				} else {
-					// Seconds
-					t := time.Unix(int64(tv), 0)
-					parsed.Time = &t
-				}
+			if t := p.parseTimeValue(v); t != nil {
+				parsed.Time = t
+				delete(data, key)
 			}
-			delete(data, key)
 			break
 		}
 	}
@@ -180,51 +319,41 @@ func (p *Parser) parseText(line string) *models.ParsedLog {
 	}
 
 	// Extract level - find the highest priority level that matches
-	highestPriority := 0
-	for level, pattern := range levelPatterns {
-		if pattern.MatchString(line) {
-			if priority := levelPriority[level]; priority > highestPriority {
-				highestPriority = priority
-				parsed.Level = level
-			}
-		}
-	}
+	parsed.Level = matchLevel(line)
 
 	// Extract timestamp
-	for _, tp := range timestampPatterns {
-		if match := tp.pattern.FindString(line); match != "" {
-			if t, err := time.Parse(tp.layout, match); err == nil {
-				// Adjust year for syslog format that doesn't include year
-				if t.Year() == 0 {
-					t = t.AddDate(time.Now().Year(), 0, 0)
-				}
-				parsed.Time = &t
-			}
-			break
-		}
-	}
+	parsed.Time = findTime(line, timestampPatterns, p.loc())
 
 	return parsed
 }
 
-func normalizeLevel(level string) string {
-	level = strings.ToUpper(strings.TrimSpace(level))
-	switch level {
-	case "DBG", "TRACE":
-		return "DEBUG"
-	case "INF", "INFORMATION":
-		return "INFO"
-	case "WRN", "WARNING":
-		return "WARN"
-	case "ERR":
-		return "ERROR"
-	case "CRIT", "CRITICAL", "PANIC":
-		return "FATAL"
-	default:
-		// Return as-is if it's a known level
-		if _, ok := levelPriority[level]; ok {
-			return level
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int64:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	}
+	return 0
+}
+
+// SetSource sets the entry's source from its transport (file path, stream
+// label, ...). A source already parsed from the line itself is kept under
+// the "logger" field, like Docker Compose prefixes take precedence over it.
+func SetSource(entry *models.LogEntry, source string) {
+	if entry.Parsed == nil {
+		entry.Parsed = &models.ParsedLog{}
+	}
+	parsed := entry.Parsed
+	if parsed.Source != "" && parsed.Source != source {
+		if parsed.Fields == nil {
+			parsed.Fields = make(map[string]any)
+		}
+		if _, exists := parsed.Fields["logger"]; !exists {
+			parsed.Fields["logger"] = parsed.Source
 		}
-		return level
 	}
+	parsed.Source = source
 }
@@ -0,0 +1,337 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+// FormatCustom tries every user-defined pattern in file order
+const FormatCustom = "custom"
+
+// patternWeight ranks user patterns above loose formats such as logfmt but
+// below the strict built-ins during auto-detection
+const patternWeight = 20
+
+// PatternFile is the JSON file read by LoadPatterns:
+//
+//	{
+//	  "definitions": {"PGLEVEL": "LOG|ERROR|WARNING|FATAL"},
+//	  "patterns": [
+//	    {
+//	      "name": "postgres",
+//	      "pattern": "%{TIMESTAMP_ISO8601:time} \\[%{INT:pid:int}\\] %{PGLEVEL:level}: +%{GREEDYDATA:message}",
+//	      "types": {"time": "2006-01-02 15:04:05.000 MST"}
+//	    }
+//	  ]
+//	}
+type PatternFile struct {
+	// Definitions adds or replaces grok pattern names usable as %{NAME}
+	Definitions map[string]string `json:"definitions"`
+	Patterns    []PatternSpec     `json:"patterns"`
+}
+
+// PatternSpec describes one named line pattern. Pattern is a Go regular
+// expression whose named groups become captures, with grok-style
+// %{NAME:field} and %{NAME:field:type} references expanded first.
+//
+// Captures named time/timestamp/ts, level/severity, message/msg and
+// source/logger fill the matching ParsedLog fields; the rest become Fields.
+// Types maps a capture to int, float, bool, duration (seconds), string or a
+// Go time layout.
+type PatternSpec struct {
+	Name    string            `json:"name"`
+	Pattern string            `json:"pattern"`
+	Types   map[string]string `json:"types"`
+}
+
+// pattern is a compiled PatternSpec
+type pattern struct {
+	name   string
+	re     *regexp.Regexp
+	fields []string // capture name per subexpression, "" if unnamed
+	types  map[string]string
+}
+
+// Built-in grok definitions, a subset of the Logstash library
+var grokDefinitions = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"INT":               `[+-]?\d+`,
+	"POSINT":            `\b[1-9]\d*\b`,
+	"NONNEGINT":         `\b\d+\b`,
+	"BASE10NUM":         `[+-]?(?:\d+(?:\.\d*)?|\.\d+)`,
+	"NUMBER":            `%{BASE10NUM}`,
+	"BASE16NUM":         `(?:0[xX])?[0-9A-Fa-f]+`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+	"QS":                `%{QUOTEDSTRING}`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:\d{1,3}\.){3}\d{1,3}`,
+	"IPV6":              `[0-9A-Fa-f]*:[0-9A-Fa-f:.]+`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":          `%{IPORHOST}:%{POSINT}`,
+	"PATH":              `(?:/[^\s]*)+`,
+	"URIPATH":           `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":          `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":               `[A-Za-z][A-Za-z0-9+\-.]*://\S+`,
+	"MONTH":             `\b(?:[Jj]an(?:uary)?|[Ff]eb(?:ruary)?|[Mm]ar(?:ch)?|[Aa]pr(?:il)?|[Mm]ay|[Jj]une?|[Jj]uly?|[Aa]ug(?:ust)?|[Ss]ep(?:tember)?|[Oo]ct(?:ober)?|[Nn]ov(?:ember)?|[Dd]ec(?:ember)?)\b`,
+	"MONTHNUM":          `(?:0?[1-9]|1[0-2])`,
+	"MONTHDAY":          `(?:(?:0[1-9])|(?:[12][0-9])|(?:3[01])|[1-9])`,
+	"DAY":               `(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)`,
+	"YEAR":              `\d{4}`,
+	"HOUR":              `(?:2[0123]|[01]?[0-9])`,
+	"MINUTE":            `[0-5][0-9]`,
+	"SECOND":            `(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?`,
+	"TIME":              `%{HOUR}:%{MINUTE}:%{SECOND}`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"DATE_US":           `%{MONTHNUM}[/-]%{MONTHDAY}[/-]%{YEAR}`,
+	"DATE_EU":           `%{MONTHDAY}[./-]%{MONTHNUM}[./-]%{YEAR}`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} [+-]\d{4}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+	"TZ":                `[A-Z]{3,5}`,
+	"LOGLEVEL":          `(?i:trace|debug|dbg|info|inf|notice|warn(?:ing)?|wrn|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?|alert|panic)`,
+}
+
+// grokRefPattern matches %{NAME}, %{NAME:field} and %{NAME:field:type}
+var grokRefPattern = regexp.MustCompile(`%\{(\w+)(?::([\w.@-]+))?(?::(\w+))?\}`)
+
+// maxGrokDepth bounds nested definition expansion, catching cycles
+const maxGrokDepth = 16
+
+// LoadPatterns reads a PatternFile and registers each pattern as a format
+// under its name, plus FormatCustom which tries all of them in order
+func (p *Parser) LoadPatterns(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var file PatternFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	defs := make(map[string]string, len(grokDefinitions)+len(file.Definitions))
+	for name, def := range grokDefinitions {
+		defs[name] = def
+	}
+	for name, def := range file.Definitions {
+		defs[name] = def
+	}
+
+	patterns := make([]*pattern, 0, len(file.Patterns))
+	names := make(map[string]bool, len(file.Patterns))
+	for i, spec := range file.Patterns {
+		switch {
+		case spec.Name == "":
+			return fmt.Errorf("%s: pattern %d has no name", path, i+1)
+		case spec.Name == FormatCustom || slices.Contains(builtinFormats, spec.Name):
+			return fmt.Errorf("%s: pattern %q: name is taken by a built-in format", path, spec.Name)
+		case names[spec.Name]:
+			return fmt.Errorf("%s: pattern %q is defined twice", path, spec.Name)
+		}
+		names[spec.Name] = true
+		pat, err := compilePattern(spec, defs)
+		if err != nil {
+			return fmt.Errorf("%s: pattern %q: %w", path, spec.Name, err)
+		}
+		patterns = append(patterns, pat)
+	}
+
+	for _, pat := range patterns {
+		if err := p.Register(pat.name, patternWeight, p.patternDecoder(pat)); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if len(patterns) > 0 {
+		return p.Register(FormatCustom, patternWeight, func(line string) *models.ParsedLog {
+			for _, pat := range patterns {
+				if parsed := p.decodePattern(pat, line); parsed != nil {
+					return parsed
+				}
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// compilePattern expands grok references and compiles the resulting regex
+func compilePattern(spec PatternSpec, defs map[string]string) (*pattern, error) {
+	types := make(map[string]string, len(spec.Types))
+	for field, typ := range spec.Types {
+		types[field] = typ
+	}
+
+	// Grok field names may contain characters Go group names can't, so
+	// captures are renamed to positional groups and mapped back afterwards
+	var names []string
+	expanded, err := expandGrok(spec.Pattern, defs, 0, func(field, typ string) string {
+		names = append(names, field)
+		if typ != "" {
+			if _, set := types[field]; !set {
+				types[field] = typ
+			}
+		}
+		return fmt.Sprintf("grok%d", len(names)-1)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := append([]string(nil), re.SubexpNames()...)
+	for i, name := range fields {
+		if n, ok := strings.CutPrefix(name, "grok"); ok {
+			if idx, err := strconv.Atoi(n); err == nil && idx < len(names) {
+				fields[i] = names[idx]
+			}
+		}
+	}
+	return &pattern{name: spec.Name, re: re, fields: fields, types: types}, nil
+}
+
+// expandGrok replaces %{NAME[:field[:type]]} with the definition of NAME,
+// wrapped in a named group when a field is given
+func expandGrok(s string, defs map[string]string, depth int, capture func(field, typ string) string) (string, error) {
+	if depth > maxGrokDepth {
+		return "", fmt.Errorf("grok definitions nest too deeply")
+	}
+
+	var err error
+	out := grokRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		m := grokRefPattern.FindStringSubmatch(ref)
+		def, ok := defs[m[1]]
+		if !ok {
+			err = fmt.Errorf("unknown grok pattern %%{%s}", m[1])
+			return ""
+		}
+		// Nested definitions never capture
+		inner, e := expandGrok(def, defs, depth+1, func(string, string) string { return "" })
+		if e != nil {
+			err = e
+			return ""
+		}
+		if m[2] == "" {
+			return "(?:" + inner + ")"
+		}
+		return "(?P<" + capture(m[2], m[3]) + ">" + inner + ")"
+	})
+	return out, err
+}
+
+// patternDecoder adapts a single pattern to the Decoder signature
+func (p *Parser) patternDecoder(pat *pattern) Decoder {
+	return func(line string) *models.ParsedLog {
+		return p.decodePattern(pat, line)
+	}
+}
+
+// decodePattern maps the captures of a matching line into a ParsedLog
+func (p *Parser) decodePattern(pat *pattern, line string) *models.ParsedLog {
+	m := pat.re.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+
+	parsed := &models.ParsedLog{Fields: make(map[string]any)}
+	for i, field := range pat.fields {
+		if i == 0 || field == "" || m[i] == "" {
+			continue
+		}
+		value := m[i]
+		typ := pat.types[field]
+
+		switch field {
+		case "time", "timestamp", "ts":
+			if t := p.parsePatternTime(value, typ); t != nil {
+				parsed.Time = t
+				continue
+			}
+		case "level", "severity":
+			parsed.Level = NormalizeLevel(value)
+			continue
+		case "message", "msg":
+			parsed.Message = value
+			continue
+		case "source", "logger":
+			parsed.Source = value
+			continue
+		}
+		parsed.Fields[field] = coerceCapture(value, typ, p.loc())
+	}
+
+	if parsed.Message == "" {
+		parsed.Message = line
+	}
+	if len(parsed.Fields) == 0 {
+		parsed.Fields = nil
+	}
+	return parsed
+}
+
+// coerceCapture converts a captured string to the configured type, keeping
+// the string when conversion fails. Zone-less time layouts are read in loc.
+func coerceCapture(value, typ string, loc *time.Location) any {
+	switch typ {
+	case "", "string":
+		return value
+	case "int":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "bool":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case "duration":
+		if d, err := time.ParseDuration(value); err == nil {
+			return d.Seconds()
+		}
+	default:
+		if t, err := time.ParseInLocation(typ, value, loc); err == nil {
+			return t
+		}
+	}
+	return value
+}
+
+// parsePatternTime parses a captured timestamp with the configured layout,
+// or like a structured time field when none is set
+func (p *Parser) parsePatternTime(value, layout string) *time.Time {
+	if layout != "" {
+		if t, err := time.ParseInLocation(layout, value, p.loc()); err == nil {
+			t = completeYear(t)
+			return &t
+		}
+		return nil
+	}
+	return p.parseTimeValue(value)
+}
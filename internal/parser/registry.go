@@ -0,0 +1,268 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+// Built-in format names
+const (
+	FormatAuto   = "auto"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+	FormatCLF    = "clf"
+	FormatSyslog = "syslog"
+	FormatText   = "text"
+)
+
+// builtinFormats are the names of the formats every parser knows about
+var builtinFormats = []string{
+	FormatAuto, FormatText, FormatJSON, FormatLogfmt, FormatCLF, FormatSyslog, FormatKlog, FormatGoLog,
+}
+
+// maxFormatCache bounds how many sources remember their detected format
+const maxFormatCache = 4096
+
+// Decoder parses a line in one log format, returning nil when the line is
+// not in that format
+type Decoder func(line string) *models.ParsedLog
+
+// format is a registered decoder. Weight breaks ties during auto-detection:
+// a strict format such as JSON outranks a loose one such as logfmt when both
+// accept a line.
+type format struct {
+	name    string
+	weight  int
+	decoder Decoder
+}
+
+// registerBuiltins adds the formats every parser knows about
+func (p *Parser) registerBuiltins() {
+	p.register(FormatJSON, 30, p.parseJSON)
+	p.register(FormatSyslog, 30, p.decodeSyslog)
+	p.register(FormatCLF, 30, p.parseAccessLog)
+	p.register(FormatKlog, 30, p.parseKlog)
+	p.register(FormatGoLog, 20, p.parseGoLog)
+	p.register(FormatLogfmt, 10, p.parseLogfmt)
+}
+
+// Register adds a named decoder, or replaces the one with the same name.
+// Decoders with a higher weight win when several accept a line.
+func (p *Parser) Register(name string, weight int, dec Decoder) error {
+	if name == "" || name == FormatAuto || name == FormatText {
+		return fmt.Errorf("format name %q is reserved", name)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.register(name, weight, dec)
+	// Earlier detections may have missed the new format
+	clear(p.detected)
+	return nil
+}
+
+// register replaces the formats slice rather than modifying it, so detect
+// can range over a snapshot without holding the lock
+func (p *Parser) register(name string, weight int, dec Decoder) {
+	formats := make([]*format, 0, len(p.formats)+1)
+	for _, existing := range p.formats {
+		if existing.name != name {
+			formats = append(formats, existing)
+		}
+	}
+	p.formats = append(formats, &format{name: name, weight: weight, decoder: dec})
+}
+
+// Formats returns the names accepted by SetFormat and SetSourceFormat
+func (p *Parser) Formats() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.formatNamesLocked()
+}
+
+// SetFormat forces every line to be decoded as name, or restores
+// auto-detection with "auto". Lines the format rejects are kept as text.
+func (p *Parser) SetFormat(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.knownFormat(name) {
+		return fmt.Errorf("unknown format %q (available: %s)", name, strings.Join(p.formatNamesLocked(), ", "))
+	}
+	p.forced = name
+	if name == FormatAuto {
+		p.forced = ""
+	}
+	return nil
+}
+
+// SetSourceFormat overrides the format for one source, such as a Docker
+// Compose service or a tailed file path
+func (p *Parser) SetSourceFormat(source, name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.knownFormat(name) {
+		return fmt.Errorf("unknown format %q (available: %s)", name, strings.Join(p.formatNamesLocked(), ", "))
+	}
+	if name == FormatAuto {
+		delete(p.overrides, source)
+	} else {
+		p.overrides[source] = name
+	}
+	return nil
+}
+
+func (p *Parser) knownFormat(name string) bool {
+	return name == FormatAuto || name == FormatText || p.lookup(name) != nil
+}
+
+func (p *Parser) formatNamesLocked() []string {
+	names := []string{FormatAuto, FormatText}
+	for _, f := range p.formats {
+		names = append(names, f.name)
+	}
+	sort.Strings(names[2:])
+	return names
+}
+
+func (p *Parser) lookup(name string) *format {
+	for _, f := range p.formats {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// decode parses line for source, then applies any custom time layouts
+func (p *Parser) decode(source, line string) *models.ParsedLog {
+	parsed := p.decodeFormat(source, line)
+	if loc, layouts := p.timeConfig(source); len(layouts) > 0 {
+		if t := findTime(line, layouts, loc); t != nil {
+			parsed.Time = t
+		}
+	}
+	ExtractTrace(parsed)
+	return parsed
+}
+
+// decodeFormat parses line using the source's configured format, the format
+// detected for its earlier lines, or auto-detection
+func (p *Parser) decodeFormat(source, line string) *models.ParsedLog {
+	p.mu.RLock()
+	name, forced := p.overrides[source]
+	if !forced && p.forced != "" {
+		name, forced = p.forced, true
+	}
+	if !forced {
+		name = p.detected[source]
+	}
+	f := p.lookup(name)
+	p.mu.RUnlock()
+
+	if forced {
+		if f != nil {
+			if parsed := f.decoder(line); parsed != nil {
+				return parsed
+			}
+		}
+		return p.parseText(line)
+	}
+
+	// Try the format this source used last time before detecting again
+	if f != nil {
+		if parsed := f.decoder(line); parsed != nil {
+			return parsed
+		}
+	}
+
+	// Lines without a source, such as stdin, may mix formats, so only
+	// sources get a remembered format; one that stopped matching is dropped
+	parsed, detected := p.detect(line)
+	if source != "" && detected != f {
+		p.mu.Lock()
+		if detected == nil {
+			delete(p.detected, source)
+		} else {
+			if len(p.detected) >= maxFormatCache {
+				clear(p.detected)
+			}
+			p.detected[source] = detected.name
+		}
+		p.mu.Unlock()
+	}
+	return parsed
+}
+
+// detect runs every decoder against line and keeps the best scoring result.
+// It returns a nil format when only the text fallback applies.
+func (p *Parser) detect(line string) (*models.ParsedLog, *format) {
+	p.mu.RLock()
+	formats := p.formats
+	p.mu.RUnlock()
+
+	var best *models.ParsedLog
+	var bestFormat *format
+	bestScore := -1
+	for _, f := range formats {
+		if f.name == FormatCustom {
+			// Its patterns are registered individually as well
+			continue
+		}
+		parsed := f.decoder(line)
+		if parsed == nil {
+			continue
+		}
+		if score := f.weight + scoreParsed(parsed); score > bestScore {
+			best, bestFormat, bestScore = parsed, f, score
+		}
+	}
+
+	if best == nil {
+		return p.parseText(line), nil
+	}
+	return best, bestFormat
+}
+
+// scoreParsed rewards decoders that extracted more structure from a line
+func scoreParsed(parsed *models.ParsedLog) int {
+	score := min(len(parsed.Fields), 5)
+	if parsed.Time != nil {
+		score += 3
+	}
+	if parsed.Level != "" {
+		score += 3
+	}
+	if parsed.Message != "" {
+		score++
+	}
+	if parsed.Source != "" {
+		score++
+	}
+	return score
+}
+
+// decodeSyslog accepts syslog lines with a "<PRI>" header as well as the
+// files written by syslog daemons, which start with an RFC 3164 timestamp
+func (p *Parser) decodeSyslog(line string) *models.ParsedLog {
+	if parsed := p.parseSyslog(line); parsed != nil {
+		return parsed
+	}
+
+	if !rfc3164Pattern.MatchString(line) {
+		return nil
+	}
+	parsed := &models.ParsedLog{Fields: make(map[string]any)}
+	msg := parseRFC3164(line, parsed, p.loc())
+	if _, ok := parsed.Fields["appname"]; !ok {
+		return nil
+	}
+	p.applyBody(msg, parsed)
+	if parsed.Level == "" {
+		parsed.Level = p.parseText(msg).Level
+	}
+	return parsed
+}
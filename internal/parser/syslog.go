@@ -0,0 +1,299 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+// Syslog severities (RFC 5424 section 6.2.1) mapped to normalized levels
+var syslogSeverityLevels = [8]string{
+	"FATAL", // 0 emergency
+	"FATAL", // 1 alert
+	"FATAL", // 2 critical
+	"ERROR", // 3 error
+	"WARN",  // 4 warning
+	"INFO",  // 5 notice
+	"INFO",  // 6 informational
+	"DEBUG", // 7 debug
+}
+
+var syslogSeverityNames = [8]string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+var syslogFacilityNames = [24]string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// SyslogSeverityLevel maps a syslog severity (0-7) to a normalized level
+func SyslogSeverityLevel(severity int) string {
+	if severity < 0 || severity >= len(syslogSeverityLevels) {
+		return ""
+	}
+	return syslogSeverityLevels[severity]
+}
+
+// RFC 3164 timestamp, e.g. "Jan  2 15:04:05"
+const rfc3164Layout = "Jan _2 15:04:05"
+
+// Start of a line written by a syslog daemon, e.g. "Jan  2 15:04:05 host app[1]: ..."
+var rfc3164Pattern = regexp.MustCompile(`^[A-Z][a-z]{2} [ 0-9]\d \d{2}:\d{2}:\d{2} `)
+
+// ParseSyslog parses an RFC 5424 or RFC 3164 message received from a syslog
+// listener. Lines that are not syslog fall back to Parse.
+func (p *Parser) ParseSyslog(line string) models.LogEntry {
+	line = strings.TrimRight(line, "\r\n\x00")
+
+	parsed := p.parseSyslog(line)
+	if parsed == nil {
+		return p.Parse(line)
+	}
+	ExtractTrace(parsed)
+
+	return models.LogEntry{
+		Timestamp: time.Now(),
+		Raw:       line,
+		Parsed:    parsed,
+	}
+}
+
+func (p *Parser) parseSyslog(line string) *models.ParsedLog {
+	pri, rest, ok := parsePRI(line)
+	if !ok {
+		return nil
+	}
+
+	severity := pri & 0x07
+	facility := pri >> 3
+
+	parsed := &models.ParsedLog{
+		Level:  syslogSeverityLevels[severity],
+		Fields: map[string]any{"severity": syslogSeverityNames[severity]},
+	}
+	if facility < len(syslogFacilityNames) {
+		parsed.Fields["facility"] = syslogFacilityNames[facility]
+	}
+
+	var msg string
+	if strings.HasPrefix(rest, "1 ") {
+		msg = parseRFC5424(rest[2:], parsed)
+	} else {
+		msg = parseRFC3164(rest, parsed, p.loc())
+	}
+
+	p.applyBody(msg, parsed)
+	return parsed
+}
+
+// parsePRI extracts the "<N>" priority prefix
+func parsePRI(line string) (int, string, bool) {
+	if len(line) < 3 || line[0] != '<' {
+		return 0, "", false
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return 0, "", false
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, "", false
+	}
+	return pri, line[end+1:], true
+}
+
+// parseRFC5424 handles "TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG"
+// and returns MSG
+func parseRFC5424(rest string, parsed *models.ParsedLog) string {
+	var header [5]string
+	for i := range header {
+		header[i], rest = nextToken(rest)
+	}
+	timestamp, hostname, appName, procID, msgID := header[0], header[1], header[2], header[3], header[4]
+
+	if timestamp != "-" {
+		if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+			parsed.Time = &t
+		}
+	}
+	setSyslogHeader(parsed, hostname, appName, procID)
+	if msgID != "-" && msgID != "" {
+		parsed.Fields["msgid"] = msgID
+	}
+
+	if strings.HasPrefix(rest, "-") {
+		rest = strings.TrimPrefix(rest[1:], " ")
+	} else if strings.HasPrefix(rest, "[") {
+		var sd map[string]any
+		sd, rest = parseStructuredData(rest)
+		for id, params := range sd {
+			parsed.Fields[id] = params
+		}
+	}
+
+	// MSG may be prefixed with a UTF-8 byte order mark
+	return strings.TrimPrefix(rest, "\ufeff")
+}
+
+// parseRFC3164 handles "Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG" and returns
+// MSG; the zone-less timestamp is read in loc
+func parseRFC3164(rest string, parsed *models.ParsedLog, loc *time.Location) string {
+	rest = strings.TrimLeft(rest, " ")
+
+	if len(rest) >= len(rfc3164Layout) {
+		if t, err := time.ParseInLocation(rfc3164Layout, rest[:len(rfc3164Layout)], loc); err == nil {
+			t = completeYear(t)
+			parsed.Time = &t
+			rest = strings.TrimLeft(rest[len(rfc3164Layout):], " ")
+		}
+	}
+	if parsed.Time == nil {
+		// Some senders (rsyslog high precision) use RFC 3339 stamps instead
+		token, after := nextToken(rest)
+		if t, err := time.Parse(time.RFC3339Nano, token); err == nil {
+			parsed.Time = &t
+			rest = after
+		}
+	}
+
+	// HOSTNAME is optional when logging locally, in which case TAG comes first
+	hostname := ""
+	if token, after := nextToken(rest); token != "" && !isSyslogTag(token) {
+		hostname = token
+		rest = after
+	}
+
+	appName, procID := "", ""
+	if token, after := nextToken(rest); isSyslogTag(token) {
+		tag := strings.TrimSuffix(token, ":")
+		if i := strings.IndexByte(tag, '['); i >= 0 && strings.HasSuffix(tag, "]") {
+			appName = tag[:i]
+			procID = tag[i+1 : len(tag)-1]
+		} else {
+			appName = tag
+		}
+		rest = after
+	}
+
+	setSyslogHeader(parsed, hostname, appName, procID)
+	return rest
+}
+
+// isSyslogTag reports whether token looks like "app:" or "app[pid]:"
+func isSyslogTag(token string) bool {
+	return strings.HasSuffix(token, ":") || strings.HasSuffix(token, "]")
+}
+
+func setSyslogHeader(parsed *models.ParsedLog, hostname, appName, procID string) {
+	if hostname != "" && hostname != "-" {
+		parsed.Fields["hostname"] = hostname
+		parsed.Source = hostname
+	}
+	if appName != "" && appName != "-" {
+		parsed.Fields["appname"] = appName
+		parsed.Source = appName
+	}
+	if procID != "" && procID != "-" {
+		parsed.Fields["procid"] = procID
+	}
+}
+
+// parseStructuredData parses one or more "[id key="value" ...]" elements
+// into a map of element ID to parameters, returning the remaining text
+func parseStructuredData(s string) (map[string]any, string) {
+	sd := make(map[string]any)
+
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+		end := strings.IndexAny(s, " ]")
+		if end < 0 {
+			break
+		}
+		id := s[:end]
+		s = s[end:]
+
+		params := make(map[string]any)
+		for strings.HasPrefix(s, " ") {
+			s = strings.TrimLeft(s, " ")
+			eq := strings.Index(s, `="`)
+			if eq < 0 {
+				break
+			}
+			name := s[:eq]
+			value, after, ok := readSDValue(s[eq+2:])
+			if !ok {
+				break
+			}
+			params[name] = value
+			s = after
+		}
+		sd[id] = params
+
+		if !strings.HasPrefix(s, "]") {
+			break
+		}
+		s = s[1:]
+	}
+
+	return sd, strings.TrimPrefix(s, " ")
+}
+
+// readSDValue reads a PARAM-VALUE up to the closing quote, handling the
+// \" \\ and \] escapes
+func readSDValue(s string) (string, string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' || s[i+1] == ']') {
+				i++
+				b.WriteByte(s[i])
+			} else {
+				b.WriteByte(c)
+			}
+		case '"':
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", s, false
+}
+
+// applyBody sets the message of an entry decoded from a transport envelope,
+// unwrapping JSON payloads so their level and fields are preserved
+func (p *Parser) applyBody(msg string, parsed *models.ParsedLog) {
+	parsed.Message = msg
+
+	body := p.parseJSON(msg)
+	if body == nil {
+		return
+	}
+	if body.Message != "" {
+		parsed.Message = body.Message
+	}
+	if body.Level != "" {
+		parsed.Level = body.Level
+	}
+	if body.Time != nil {
+		parsed.Time = body.Time
+	}
+	for k, v := range body.Fields {
+		if _, exists := parsed.Fields[k]; !exists {
+			parsed.Fields[k] = v
+		}
+	}
+}
+
+func nextToken(s string) (string, string) {
+	s = strings.TrimLeft(s, " ")
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
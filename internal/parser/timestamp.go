@@ -0,0 +1,334 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timestampPattern finds a timestamp inside a line and parses it. Layouts
+// without a zone are read in the parser's location.
+type timestampPattern struct {
+	pattern *regexp.Regexp
+	layouts []string
+	// normalize rewrites the match into a form the layouts accept
+	normalize func(string) string
+}
+
+// Common timestamp patterns, most specific first
+var timestampPatterns = []timestampPattern{
+	// ISO 8601 / RFC 3339 with a zone; also "2006-01-02 15:04:05,000 +0000"
+	{
+		pattern:   regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)? ?(?:Z|[+-]\d{2}:?\d{2})\b`),
+		layouts:   []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999Z0700"},
+		normalize: normalizeISO,
+	},
+	// Zone-less ISO and Python/log4j "2006-01-02 15:04:05,000"
+	{
+		pattern:   regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?`),
+		layouts:   []string{"2006-01-02T15:04:05.999999999"},
+		normalize: normalizeISO,
+	},
+	// Go standard log "2006/01/02 15:04:05.000000"
+	{
+		pattern: regexp.MustCompile(`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?`),
+		layouts: []string{"2006/01/02 15:04:05"},
+	},
+	// Common Log Format "[02/Jan/2006:15:04:05 -0700]"
+	{
+		pattern: regexp.MustCompile(`\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`),
+		layouts: []string{clfTimeLayout},
+	},
+	{
+		pattern: regexp.MustCompile(`\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2}`),
+		layouts: []string{"02/Jan/2006:15:04:05"},
+	},
+	// klog/glog header "I0102 15:04:05.000000"
+	{
+		pattern:   regexp.MustCompile(`^[IWEF]\d{4} \d{2}:\d{2}:\d{2}(?:\.\d+)?`),
+		layouts:   []string{"0102 15:04:05"},
+		normalize: func(s string) string { return s[1:] },
+	},
+	// Syslog "Jan  2 15:04:05"
+	{
+		pattern: regexp.MustCompile(`\w{3} +\d{1,2} \d{2}:\d{2}:\d{2}`),
+		layouts: []string{"Jan _2 15:04:05"},
+	},
+}
+
+// normalizeISO accepts a space date/time separator, comma fractions and a
+// space before the zone
+func normalizeISO(s string) string {
+	b := []byte(s)
+	if len(b) > 10 && b[10] == ' ' {
+		b[10] = 'T'
+	}
+	if len(b) > 19 && b[19] == ',' {
+		b[19] = '.'
+	}
+	return strings.Replace(string(b), " ", "", 1)
+}
+
+// SetLocation sets the time zone assumed for timestamps that carry none
+// (default UTC)
+func (p *Parser) SetLocation(loc *time.Location) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.location = loc
+}
+
+// SetTimeLayout adds a Go time layout tried before the built-in patterns
+// for every source
+func (p *Parser) SetTimeLayout(layout string) error {
+	return p.SetSourceTimeLayout("", layout)
+}
+
+// SetSourceTimeLayout adds a Go time layout tried before the built-in
+// patterns for one source; an empty source applies it to all of them.
+// A timestamp found with a custom layout wins over one the format decoder
+// extracted.
+func (p *Parser) SetSourceTimeLayout(source, layout string) error {
+	re, err := layoutPattern(layout)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tp := timestampPattern{pattern: re, layouts: []string{layout}}
+	if source == "" {
+		p.timeLayouts = append(p.timeLayouts, tp)
+	} else {
+		p.sourceTimeLayouts[source] = append(p.sourceTimeLayouts[source], tp)
+	}
+	return nil
+}
+
+// timeConfig returns the location and custom layouts for source
+func (p *Parser) timeConfig(source string) (*time.Location, []timestampPattern) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if layouts, ok := p.sourceTimeLayouts[source]; ok {
+		return p.location, layouts
+	}
+	return p.location, p.timeLayouts
+}
+
+// loc returns the location for zone-less timestamps
+func (p *Parser) loc() *time.Location {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.location
+}
+
+// findTime returns the first timestamp in s matching one of patterns
+func findTime(s string, patterns []timestampPattern, loc *time.Location) *time.Time {
+	for _, tp := range patterns {
+		match := tp.pattern.FindString(s)
+		if match == "" {
+			continue
+		}
+		if tp.normalize != nil {
+			match = tp.normalize(match)
+		}
+		for _, layout := range tp.layouts {
+			if t, err := time.ParseInLocation(layout, match, loc); err == nil {
+				t = completeYear(t)
+				return &t
+			}
+		}
+	}
+	return nil
+}
+
+// completeYear fills in the year for stamps that omit it (syslog, klog),
+// picking last year when the current one would put the stamp in the future
+func completeYear(t time.Time) time.Time {
+	if t.Year() != 0 {
+		return t
+	}
+	now := time.Now()
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+// parseTimeValue converts a structured time field: RFC 3339 and the
+// built-in layouts as strings, or Unix epochs in seconds, milliseconds,
+// microseconds or nanoseconds as numbers or numeric strings
+func (p *Parser) parseTimeValue(v any) *time.Time {
+	switch tv := v.(type) {
+	case string:
+		s := strings.TrimSpace(tv)
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return &t
+		}
+		if isEpochString(s) {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				if !strings.Contains(s, ".") {
+					if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+						return epochTime(n)
+					}
+				}
+				return epochFloatTime(f)
+			}
+		}
+		return findTime(s, timestampPatterns, p.loc())
+	case int64:
+		return epochTime(tv)
+	case uint64:
+		if tv <= math.MaxInt64 {
+			return epochTime(int64(tv))
+		}
+	case float64:
+		if tv == math.Trunc(tv) && math.Abs(tv) < 1<<53 {
+			return epochTime(int64(tv))
+		}
+		return epochFloatTime(tv)
+	}
+	return nil
+}
+
+// isEpochString reports whether s is digits with at most one decimal point
+func isEpochString(s string) bool {
+	if s == "" {
+		return false
+	}
+	dot := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '.' && !dot && i > 0:
+			dot = true
+		case c < '0' || c > '9':
+			return false
+		}
+	}
+	return true
+}
+
+// epochTime interprets n by magnitude as seconds, milliseconds,
+// microseconds or nanoseconds since the Unix epoch
+func epochTime(n int64) *time.Time {
+	var t time.Time
+	switch abs := max(n, -n); {
+	case abs >= 1e17:
+		t = time.Unix(0, n)
+	case abs >= 1e14:
+		t = time.UnixMicro(n)
+	case abs >= 1e11:
+		t = time.UnixMilli(n)
+	default:
+		t = time.Unix(n, 0)
+	}
+	return &t
+}
+
+// epochFloatTime handles fractional epochs such as 1700000000.123
+func epochFloatTime(f float64) *time.Time {
+	switch abs := math.Abs(f); {
+	case abs >= 1e17:
+		return epochTime(int64(f))
+	case abs >= 1e14:
+		t := time.UnixMicro(int64(f))
+		return &t
+	case abs >= 1e11:
+		t := time.UnixMicro(int64(f * 1e3))
+		return &t
+	}
+	sec, frac := math.Modf(f)
+	t := time.Unix(int64(sec), int64(frac*1e9))
+	return &t
+}
+
+// layoutTokens maps Go reference time elements to the text they match,
+// longest first so "2006" wins over "2" and "January" over "Jan"
+var layoutTokens = []struct {
+	token   string
+	pattern string
+}{
+	{"January", `[A-Z][a-z]+`},
+	{"Monday", `[A-Z][a-z]+`},
+	{"Z07:00:00", `(?:Z|[+-]\d{2}:\d{2}:\d{2})`},
+	{"-07:00:00", `[+-]\d{2}:\d{2}:\d{2}`},
+	{"Z07:00", `(?:Z|[+-]\d{2}:\d{2})`},
+	{"-07:00", `[+-]\d{2}:\d{2}`},
+	{"Z0700", `(?:Z|[+-]\d{4})`},
+	{"-0700", `[+-]\d{4}`},
+	{"2006", `\d{4}`},
+	{"Z07", `(?:Z|[+-]\d{2})`},
+	{"-07", `[+-]\d{2}`},
+	{"Jan", `[A-Z][a-z]{2}`},
+	{"Mon", `[A-Z][a-z]{2}`},
+	{"MST", `[A-Z]{2,5}`},
+	{"__2", `[ \d]{2}\d`},
+	{"002", `\d{3}`},
+	{"_2", `[ \d]\d`},
+	{"01", `\d{2}`},
+	{"02", `\d{2}`},
+	{"03", `\d{2}`},
+	{"04", `\d{2}`},
+	{"05", `\d{2}`},
+	{"06", `\d{2}`},
+	{"15", `\d{2}`},
+	{"PM", `[AP]M`},
+	{"pm", `[ap]m`},
+	{"1", `\d{1,2}`},
+	{"2", `\d{1,2}`},
+	{"3", `\d{1,2}`},
+	{"4", `\d{1,2}`},
+	{"5", `\d{1,2}`},
+}
+
+// layoutPattern builds a regex matching the text a Go time layout produces,
+// used to find custom-layout timestamps inside a line
+func layoutPattern(layout string) (*regexp.Regexp, error) {
+	if layout == "" {
+		return nil, fmt.Errorf("empty time layout")
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(layout); {
+		// Fractional seconds: ".000"/",000" fixed width, ".999" optional
+		if c := layout[i]; (c == '.' || c == ',') && i+1 < len(layout) && (layout[i+1] == '0' || layout[i+1] == '9') {
+			j := i + 1
+			for j < len(layout) && layout[j] == layout[i+1] {
+				j++
+			}
+			if layout[i+1] == '0' {
+				fmt.Fprintf(&b, `[.,]\d{%d}`, j-i-1)
+			} else {
+				b.WriteString(`(?:[.,]\d+)?`)
+			}
+			i = j
+			continue
+		}
+
+		matched := false
+		for _, lt := range layoutTokens {
+			if strings.HasPrefix(layout[i:], lt.token) {
+				b.WriteString(lt.pattern)
+				i += len(lt.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteString(regexp.QuoteMeta(layout[i : i+1]))
+			i++
+		}
+	}
+
+	sample := time.Now().Format(layout)
+	if sample == layout {
+		return nil, fmt.Errorf("time layout %q has no date or time elements", layout)
+	}
+	if _, err := time.Parse(layout, sample); err != nil {
+		return nil, fmt.Errorf("invalid time layout %q: %w", layout, err)
+	}
+	return regexp.Compile(b.String())
+}
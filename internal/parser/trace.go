@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"encoding/json"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+// Correlation ids found in plain text lines
+var (
+	// W3C traceparent: version-traceid-parentid-flags
+	traceparentPattern = regexp.MustCompile(`\b[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}\b`)
+	// "trace_id=abc", "spanId: abc", "x-request-id=abc"
+	traceKeyPattern = regexp.MustCompile(`(?i)\b((?:dd\.)?trace[_.-]?id|(?:dd\.)?span[_.-]?id|parent[_.-]?span[_.-]?id|(?:x-)?req(?:uest)?[_-]?id)["']?\s*[=:]\s*["']?([0-9A-Za-z][0-9A-Za-z_-]*)`)
+)
+
+// Field keys holding correlation ids, compared after normalizeIDKey. Nested
+// objects are flattened first, so {"dd": {"trace_id": 1}} is "ddtraceid".
+var (
+	traceIDKeys      = []string{"traceid", "ddtraceid", "xb3traceid", "oteltraceid"}
+	spanIDKeys       = []string{"spanid", "ddspanid", "xb3spanid", "otelspanid"}
+	parentSpanIDKeys = []string{"parentspanid", "xb3parentspanid"}
+	requestIDKeys    = []string{"requestid", "xrequestid", "reqid", "xcorrelationid", "correlationid"}
+)
+
+// normalizeIDKey lower-cases a field key and drops separators, so trace_id,
+// traceId, trace.id and X-Trace-Id compare equal
+func normalizeIDKey(key string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(key) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// idValue is an id found in a record's fields
+type idValue struct {
+	value string
+	field string // top-level key in Fields, "" for nested values
+}
+
+// ExtractTrace fills the trace, span, parent span and request ids of parsed
+// from its fields (W3C traceparent, B3 headers, OpenTelemetry, Datadog and
+// x-request-id conventions) or, failing that, from the message text.
+// Top-level fields whose value was used are removed from Fields.
+func ExtractTrace(parsed *models.ParsedLog) {
+	if parsed == nil {
+		return
+	}
+
+	ids := make(map[string]idValue)
+	collectIDs(parsed.Fields, "", ids, true)
+	used := make(map[string]bool)
+
+	// Header forms carry several ids in one value
+	if v, ok := ids["traceparent"]; ok {
+		if m := traceparentPattern.FindStringSubmatch(strings.ToLower(v.value)); m != nil {
+			trace, span := setID(&parsed.TraceID, m[1]), setID(&parsed.SpanID, m[2])
+			used["traceparent"] = trace || span
+		}
+	}
+	if v, ok := ids["b3"]; ok {
+		// traceid-spanid[-sampled[-parentspanid]]
+		parts := strings.Split(v.value, "-")
+		if len(parts) >= 2 {
+			trace, span := setID(&parsed.TraceID, parts[0]), setID(&parsed.SpanID, parts[1])
+			used["b3"] = trace || span
+		}
+		if len(parts) == 4 && setID(&parsed.ParentSpanID, parts[3]) {
+			used["b3"] = true
+		}
+	}
+
+	for _, target := range []struct {
+		dst  *string
+		keys []string
+	}{
+		{&parsed.TraceID, traceIDKeys},
+		{&parsed.SpanID, spanIDKeys},
+		{&parsed.ParentSpanID, parentSpanIDKeys},
+		{&parsed.RequestID, requestIDKeys},
+	} {
+		if key := firstID(ids, target.keys); key != "" && setID(target.dst, ids[key].value) {
+			used[key] = true
+		}
+	}
+
+	for key, ok := range used {
+		if field := ids[key].field; ok && field != "" {
+			delete(parsed.Fields, field)
+		}
+	}
+
+	if parsed.TraceID == "" || parsed.RequestID == "" {
+		extractTextTrace(parsed)
+	}
+}
+
+// collectIDs gathers string and numeric values of known id keys, recursing
+// into nested objects after the values beside them. Keys are visited in
+// order, so of several spellings of one id (traceId, trace_id) the first
+// wins. Nested keys such as syslog structured data ({"meta": {"trace_id":
+// ...}}) are also tried without their prefix.
+func collectIDs(fields map[string]any, prefix string, ids map[string]idValue, topLevel bool) {
+	keys := slices.Sorted(maps.Keys(fields))
+	for _, k := range keys {
+		if _, ok := fields[k].(map[string]any); ok {
+			continue
+		}
+		key := prefix + normalizeIDKey(k)
+		if !isIDKey(key) {
+			key = normalizeIDKey(k)
+		}
+		if _, seen := ids[key]; seen || !isIDKey(key) {
+			continue
+		}
+		s := idString(fields[k])
+		if s == "" {
+			continue
+		}
+		id := idValue{value: s}
+		if topLevel {
+			id.field = k
+		}
+		ids[key] = id
+	}
+	for _, k := range keys {
+		if nested, ok := fields[k].(map[string]any); ok {
+			collectIDs(nested, prefix+normalizeIDKey(k), ids, false)
+		}
+	}
+}
+
+func isIDKey(key string) bool {
+	return key == "traceparent" || key == "b3" || idTarget(&models.ParsedLog{}, key) != nil
+}
+
+// idTarget returns the field of parsed that a normalized id key fills
+func idTarget(parsed *models.ParsedLog, key string) *string {
+	switch {
+	case slices.Contains(traceIDKeys, key):
+		return &parsed.TraceID
+	case slices.Contains(spanIDKeys, key):
+		return &parsed.SpanID
+	case slices.Contains(parentSpanIDKeys, key):
+		return &parsed.ParentSpanID
+	case slices.Contains(requestIDKeys, key):
+		return &parsed.RequestID
+	}
+	return nil
+}
+
+// idString formats an id value. Datadog logs 64-bit ids as decimal
+// numbers, which decodeObject keeps as integers when a float64 can't.
+func idString(v any) string {
+	switch tv := v.(type) {
+	case string:
+		return strings.TrimSpace(tv)
+	case json.Number:
+		return tv.String()
+	case int64:
+		return strconv.FormatInt(tv, 10)
+	case uint64:
+		return strconv.FormatUint(tv, 10)
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64)
+	}
+	return ""
+}
+
+// firstID returns the first of keys that ids holds, or ""
+func firstID(ids map[string]idValue, keys []string) string {
+	for _, k := range keys {
+		if _, ok := ids[k]; ok {
+			return k
+		}
+	}
+	return ""
+}
+
+// setID stores id unless a value was already found, reporting whether it
+// did. Hex ids (and UUIDs) are lower-cased so the same trace matches across
+// services.
+func setID(dst *string, id string) bool {
+	if *dst != "" || id == "" {
+		return false
+	}
+	if strings.Trim(strings.ToLower(id), "0123456789abcdef-") == "" {
+		id = strings.ToLower(id)
+	}
+	*dst = id
+	return true
+}
+
+// extractTextTrace looks for traceparent headers and key=value ids in the
+// message of a plain text line
+func extractTextTrace(parsed *models.ParsedLog) {
+	msg := parsed.Message
+	if m := traceparentPattern.FindStringSubmatch(msg); m != nil {
+		setID(&parsed.TraceID, m[1])
+		setID(&parsed.SpanID, m[2])
+	}
+	for _, m := range traceKeyPattern.FindAllStringSubmatch(msg, -1) {
+		if dst := idTarget(parsed, normalizeIDKey(m[1])); dst != nil {
+			setID(dst, m[2])
+		}
+	}
+}
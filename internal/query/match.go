@@ -0,0 +1,358 @@
+package query
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lch88/logbro/internal/models"
+	"github.com/lch88/logbro/internal/parser"
+)
+
+// Expr is a node of a parsed query
+type Expr interface {
+	Match(entry *models.LogEntry) bool
+}
+
+// And matches entries every sub-expression matches
+type And []Expr
+
+// Or matches entries any sub-expression matches
+type Or []Expr
+
+// Not matches entries its expression doesn't
+type Not struct {
+	Expr Expr
+}
+
+// Text matches entries whose raw line contains Value, ignoring case
+type Text struct {
+	Value string
+	lower string
+}
+
+// Compare matches entries whose field compares to Value. Fields holding a
+// list match when any item does; a missing field only matches Ne.
+type Compare struct {
+	Field string
+	Op    Op
+	Value string
+
+	lower  string // Value lower-cased, for wildcards
+	glob   bool
+	level  int // priority of Value for ordered level comparisons
+	num    float64
+	isNum  bool
+	time   time.Time
+	isTime bool
+}
+
+func newText(value string) *Text {
+	return &Text{Value: value, lower: strings.ToLower(value)}
+}
+
+func (e And) Match(entry *models.LogEntry) bool {
+	for _, expr := range e {
+		if !expr.Match(entry) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e Or) Match(entry *models.LogEntry) bool {
+	for _, expr := range e {
+		if expr.Match(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e Not) Match(entry *models.LogEntry) bool {
+	return !e.Expr.Match(entry)
+}
+
+func (e *Text) Match(entry *models.LogEntry) bool {
+	return ContainsFold(entry.Raw, e.lower)
+}
+
+func (c *Compare) Match(entry *models.LogEntry) bool {
+	v, ok := Lookup(entry, c.Field)
+	matched := ok && c.matchAny(v)
+	if c.Op == Ne {
+		return !matched
+	}
+	return matched
+}
+
+func (c *Compare) matchAny(v any) bool {
+	if list, ok := v.([]any); ok {
+		return slices.ContainsFunc(list, c.matchValue)
+	}
+	return c.matchValue(v)
+}
+
+// matchValue compares one value; Ne is answered by negating Eq
+func (c *Compare) matchValue(v any) bool {
+	if c.Op == Eq || c.Op == Ne {
+		return c.equal(v)
+	}
+
+	var order int
+	switch {
+	case c.level > 0:
+		s, _ := v.(string)
+		priority := parser.LevelPriority(s)
+		if priority == 0 {
+			return false
+		}
+		order = cmp.Compare(priority, c.level)
+	case c.isNum:
+		n, ok := toNumber(v)
+		if !ok {
+			return false
+		}
+		order = cmp.Compare(n, c.num)
+	case c.isTime:
+		t, ok := toTime(v)
+		if !ok {
+			return false
+		}
+		order = t.Compare(c.time)
+	default:
+		s, ok := toText(v)
+		if !ok {
+			return false
+		}
+		order = strings.Compare(s, c.Value)
+	}
+
+	switch c.Op {
+	case Lt:
+		return order < 0
+	case Le:
+		return order <= 0
+	case Gt:
+		return order > 0
+	}
+	return order >= 0
+}
+
+func (c *Compare) equal(v any) bool {
+	if c.Value == "*" {
+		s, ok := v.(string)
+		return v != nil && (!ok || s != "")
+	}
+	if c.isNum {
+		if n, ok := toNumber(v); ok {
+			return n == c.num
+		}
+	}
+	s, ok := toText(v)
+	if !ok {
+		return false
+	}
+	if c.glob {
+		return globFold(strings.ToLower(s), c.lower)
+	}
+	return strings.EqualFold(s, c.Value)
+}
+
+// Lookup returns an entry's value for a query field: level, source,
+// message (or msg), raw, stream, id, time, trace_id, span_id,
+// parent_span_id and request_id are built in; other names are parsed fields,
+// with dots reaching into nested objects. Empty values count as missing.
+func Lookup(entry *models.LogEntry, field string) (any, bool) {
+	var s string
+	p := entry.Parsed
+	if p == nil {
+		p = &models.ParsedLog{}
+	}
+	switch strings.ToLower(strings.ReplaceAll(field, "_", "")) {
+	case "level":
+		s = p.Level
+	case "source":
+		s = p.Source
+	case "message", "msg":
+		s = p.Message
+	case "raw":
+		s = entry.Raw
+	case "stream":
+		s = entry.Stream
+	case "traceid":
+		s = p.TraceID
+	case "spanid":
+		s = p.SpanID
+	case "parentspanid":
+		s = p.ParentSpanID
+	case "requestid":
+		s = p.RequestID
+	case "id":
+		return entry.ID, true
+	case "time":
+		return LogTime(*entry), true
+	default:
+		return lookupPath(p.Fields, field)
+	}
+	return s, s != ""
+}
+
+func isLevelField(field string) bool {
+	return strings.EqualFold(field, "level")
+}
+
+// lookupPath finds a dotted path in nested fields. Keys that contain dots
+// themselves are tried before descending.
+func lookupPath(fields map[string]any, path string) (any, bool) {
+	if v, ok := fields[path]; ok {
+		return v, true
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if nested, ok := fields[path[:i]].(map[string]any); ok {
+			if v, ok := lookupPath(nested, path[i+1:]); ok {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Terms returns the text every entry matching expr contains, ignoring case,
+// so callers can narrow down candidates with a text index
+func Terms(expr Expr) []string {
+	switch e := expr.(type) {
+	case *Text:
+		return []string{e.lower}
+	case And:
+		var terms []string
+		for _, sub := range e {
+			terms = append(terms, Terms(sub)...)
+		}
+		return terms
+	}
+	return nil
+}
+
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		return parseNumber(n)
+	}
+	return 0, false
+}
+
+func parseNumber(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f, err == nil
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		return parseTime(t)
+	}
+	return time.Time{}, false
+}
+
+// toText formats scalar values for string comparison; objects have none
+func toText(v any) (string, bool) {
+	switch tv := v.(type) {
+	case string:
+		return tv, true
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(tv), true
+	case int64:
+		return strconv.FormatInt(tv, 10), true
+	case uint64:
+		return strconv.FormatUint(tv, 10), true
+	case bool:
+		return strconv.FormatBool(tv), true
+	case json.Number:
+		return tv.String(), true
+	case time.Time:
+		return tv.Format(time.RFC3339Nano), true
+	}
+	return "", false
+}
+
+// globFold matches s against a pattern where '*' matches any run of
+// characters; both must already be lower-cased
+func globFold(s, pattern string) bool {
+	star, resume := -1, 0
+	i, j := 0, 0
+	for i < len(s) {
+		switch {
+		case j < len(pattern) && pattern[j] == '*':
+			star, resume = j, i
+			j++
+		case j < len(pattern) && pattern[j] == s[i]:
+			i++
+			j++
+		case star >= 0:
+			resume++
+			i, j = resume, star+1
+		default:
+			return false
+		}
+	}
+	for j < len(pattern) && pattern[j] == '*' {
+		j++
+	}
+	return j == len(pattern)
+}
+
+// ContainsFold reports whether the Unicode lower-case form of s contains
+// lower, which must already be lower-cased. ASCII text is compared without
+// allocating.
+func ContainsFold(s, lower string) bool {
+	if lower == "" {
+		return true
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return strings.Contains(strings.ToLower(s), lower)
+		}
+	}
+	for i := 0; i < len(lower); i++ {
+		if lower[i] >= utf8.RuneSelf {
+			return false // ASCII text has an ASCII lower-case form
+		}
+	}
+	first := lower[0]
+	for i := 0; i+len(lower) <= len(s); i++ {
+		if lowerASCII(s[i]) == first && strings.EqualFold(s[i:i+len(lower)], lower) {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
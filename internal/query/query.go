@@ -0,0 +1,360 @@
+// Package query parses and evaluates the structured log query language:
+//
+//	level:>=warn source:api status:5* duration_ms>500 NOT "health check" (user:alice OR user:bob)
+//
+// Terms are combined with AND (implied between adjacent terms), OR and NOT
+// (or a leading '-'), grouped with parentheses. A field term compares a
+// field with ':' or '=' (equal, '*' as wildcard), '!=', '>', '>=', '<' and
+// '<='; any other term matches the raw line as case-insensitive text.
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lch88/logbro/internal/parser"
+)
+
+// Op is a field comparison
+type Op int
+
+const (
+	Eq Op = iota // ':' or '='
+	Ne           // '!='
+	Lt           // '<'
+	Le           // '<='
+	Gt           // '>'
+	Ge           // '>='
+)
+
+// operators maps query syntax to comparisons, longest first so a prefix
+// doesn't shadow a longer operator
+var operators = []struct {
+	text string
+	op   Op
+}{
+	{":>=", Ge}, {":<=", Le}, {">=", Ge}, {"<=", Le}, {"!=", Ne},
+	{":>", Gt}, {":<", Lt}, {">", Gt}, {"<", Lt}, {"=", Eq}, {":", Eq},
+}
+
+// SyntaxError reports where and why a query failed to parse
+type SyntaxError struct {
+	Pos int // byte offset into the query
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos+1)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+	tokTerm
+)
+
+type token struct {
+	kind     tokenKind
+	pos      int
+	field    string // empty for a text term
+	op       Op
+	value    string
+	valuePos int
+	quoted   bool
+}
+
+// Parse parses a query. An empty query returns a nil Expr, which callers
+// treat as matching everything.
+func Parse(input string) (Expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, nil
+	}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.unexpected(tok)
+	}
+	return expr, nil
+}
+
+// lex splits a query into tokens, ending with tokEOF
+func lex(s string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i == len(s) {
+			return append(tokens, token{kind: tokEOF, pos: i}), nil
+		}
+
+		switch c := s[i]; {
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, pos: i})
+			i++
+		case c == '-' && i+1 < len(s) && !isSpace(s[i+1]) && s[i+1] != ')':
+			// A leading '-' negates the term or group after it
+			tokens = append(tokens, token{kind: tokNot, pos: i})
+			i++
+		case c == '"':
+			value, end, err := readQuoted(s, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokTerm, pos: i, value: value, valuePos: i, quoted: true})
+			i = end
+		default:
+			tok, end, err := lexWord(s, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i = end
+		}
+	}
+}
+
+// lexWord reads a keyword, a bare text term or a field comparison
+func lexWord(s string, start int) (token, int, error) {
+	i := start
+	for i < len(s) && !isDelim(s[i]) && operatorAt(s, i) < 0 {
+		i++
+	}
+	word := s[start:i]
+
+	n := operatorAt(s, i)
+	if n < 0 {
+		switch word {
+		case "AND":
+			return token{kind: tokAnd, pos: start}, i, nil
+		case "OR":
+			return token{kind: tokOr, pos: start}, i, nil
+		case "NOT":
+			return token{kind: tokNot, pos: start}, i, nil
+		}
+		return token{kind: tokTerm, pos: start, value: word, valuePos: start}, i, nil
+	}
+
+	opText := operators[n].text
+	if word == "" {
+		return token{}, 0, &SyntaxError{start, fmt.Sprintf("missing field name before %q", opText)}
+	}
+	tok := token{kind: tokTerm, pos: start, field: word, op: operators[n].op}
+	i += len(opText)
+	tok.valuePos = i
+
+	if i < len(s) && s[i] == '"' {
+		value, end, err := readQuoted(s, i)
+		if err != nil {
+			return token{}, 0, err
+		}
+		tok.value, tok.quoted = value, true
+		return tok, end, nil
+	}
+	end := i
+	for end < len(s) && !isDelim(s[end]) {
+		end++
+	}
+	if end == i {
+		return token{}, 0, &SyntaxError{i, fmt.Sprintf("missing value after %q (quote text to search for it)", word+opText)}
+	}
+	tok.value = s[i:end]
+	return tok, end, nil
+}
+
+// readQuoted reads a double-quoted string starting at s[start], with
+// backslash escapes, returning its value and the offset after it
+func readQuoted(s string, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, &SyntaxError{start, "unterminated quoted string"}
+}
+
+// operatorAt returns the index in operators of the operator at s[i], or -1
+func operatorAt(s string, i int) int {
+	for n, o := range operators {
+		if strings.HasPrefix(s[i:], o.text) {
+			return n
+		}
+	}
+	return -1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDelim(c byte) bool {
+	return isSpace(c) || c == '(' || c == ')' || c == '"'
+}
+
+type queryParser struct {
+	tokens []token
+	i      int
+}
+
+func (p *queryParser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *queryParser) next() token {
+	tok := p.tokens[p.i]
+	if tok.kind != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+// parseOr parses terms joined by OR, which binds loosest
+func (p *queryParser) parseOr() (Expr, error) {
+	var terms Or
+	for {
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, expr)
+		if p.peek().kind != tokOr {
+			break
+		}
+		p.next()
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+// parseAnd parses terms joined by AND or placed next to each other
+func (p *queryParser) parseAnd() (Expr, error) {
+	var terms And
+	for {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, expr)
+
+		if p.peek().kind == tokAnd {
+			p.next()
+			continue
+		}
+		if kind := p.peek().kind; kind != tokTerm && kind != tokNot && kind != tokLParen {
+			break
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+func (p *queryParser) parseUnary() (Expr, error) {
+	if p.peek().kind == tokNot {
+		p.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (Expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		if p.peek().kind == tokRParen {
+			return nil, &SyntaxError{tok.pos, "empty parentheses"}
+		}
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, &SyntaxError{tok.pos, "missing closing ')' for '('"}
+		}
+		return expr, nil
+	case tokTerm:
+		if tok.field == "" {
+			return newText(tok.value), nil
+		}
+		return newCompare(tok)
+	}
+	return nil, p.unexpected(tok)
+}
+
+func (p *queryParser) unexpected(tok token) error {
+	switch tok.kind {
+	case tokEOF:
+		return &SyntaxError{tok.pos, "unexpected end of query"}
+	case tokRParen:
+		return &SyntaxError{tok.pos, "unexpected ')'"}
+	case tokAnd:
+		return &SyntaxError{tok.pos, "unexpected AND"}
+	case tokOr:
+		return &SyntaxError{tok.pos, "unexpected OR"}
+	}
+	return &SyntaxError{tok.pos, "unexpected term"}
+}
+
+// newCompare checks and prepares a field comparison
+func newCompare(tok token) (*Compare, error) {
+	c := &Compare{Field: tok.field, Op: tok.op, Value: tok.value, lower: strings.ToLower(tok.value)}
+	c.glob = strings.Contains(tok.value, "*")
+	ordered := c.Op != Eq && c.Op != Ne
+
+	if ordered && c.glob {
+		return nil, &SyntaxError{tok.valuePos, fmt.Sprintf("wildcards can't be used in %q comparisons", tok.field)}
+	}
+	if isLevelField(tok.field) {
+		if ordered {
+			c.level = parser.LevelPriority(parser.NormalizeLevel(tok.value))
+			if c.level == 0 {
+				return nil, &SyntaxError{tok.valuePos, fmt.Sprintf("unknown level %q", tok.value)}
+			}
+		} else if !c.glob {
+			c.Value = parser.NormalizeLevel(tok.value)
+		}
+		return c, nil
+	}
+
+	if !c.glob && !tok.quoted {
+		c.num, c.isNum = parseNumber(tok.value)
+	}
+	if ordered && !c.isNum {
+		c.time, c.isTime = parseTime(tok.value)
+	}
+	return c, nil
+}
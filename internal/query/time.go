@@ -0,0 +1,128 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+// timeLayouts are the forms accepted for time values, read in local time
+// when they have no zone
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Values of LogFilter.TimeField and LogFilter.Order
+const (
+	TimeArrival = "arrival"
+	TimeLog     = "log"
+)
+
+// InRange matches entries whose time lies within a range
+type InRange struct {
+	Range models.TimeRange
+}
+
+func (e InRange) Match(entry *models.LogEntry) bool {
+	t := entry.Timestamp
+	if e.Range.LogTime {
+		t = LogTime(*entry)
+	}
+	if !e.Range.Since.IsZero() && t.Before(e.Range.Since) {
+		return false
+	}
+	return e.Range.Until.IsZero() || t.Before(e.Range.Until)
+}
+
+// LogTime is when an entry was logged, falling back to when it arrived
+func LogTime(entry models.LogEntry) time.Time {
+	if entry.Parsed != nil && entry.Parsed.Time != nil {
+		return *entry.Parsed.Time
+	}
+	return entry.Timestamp
+}
+
+// Range resolves a filter's Since and Until against now
+func Range(filter models.LogFilter, now time.Time) (models.TimeRange, error) {
+	var tr models.TimeRange
+	switch filter.TimeField {
+	case "", TimeArrival:
+	case TimeLog:
+		tr.LogTime = true
+	default:
+		return tr, fmt.Errorf("invalid timeField %q, want %q or %q", filter.TimeField, TimeArrival, TimeLog)
+	}
+
+	var err error
+	if filter.Since != "" {
+		if tr.Since, err = ParseTime(filter.Since, now); err != nil {
+			return tr, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if filter.Until != "" {
+		if tr.Until, err = ParseTime(filter.Until, now); err != nil {
+			return tr, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+	return tr, nil
+}
+
+// Compile parses a filter's query and adds its time range, returning nil
+// when the filter has neither
+func Compile(filter models.LogFilter, now time.Time) (Expr, error) {
+	expr, err := Parse(filter.Query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	tr, err := Range(filter, now)
+	if err != nil {
+		return nil, err
+	}
+	if tr.Since.IsZero() && tr.Until.IsZero() {
+		return expr, nil
+	}
+	if expr == nil {
+		return InRange{tr}, nil
+	}
+	// The cheap time check goes first
+	return And{InRange{tr}, expr}, nil
+}
+
+// ParseTime reads a time bound: a duration before now such as 90s, 15m,
+// 2h or 7d, "now", or an absolute time (RFC 3339, or a local date and
+// time such as 2024-01-15 10:30)
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "now" {
+		return now, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.ParseFloat(days, 64); err == nil && n >= 0 {
+			return now.Add(-time.Duration(n * float64(24*time.Hour))), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, ok := parseTime(s); ok {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a time nor a duration such as 15m", s)
+}
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
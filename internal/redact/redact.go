@@ -0,0 +1,357 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/lch88/logbro/internal/ansi"
+	"github.com/lch88/logbro/internal/models"
+)
+
+// Action is what happens to a detected secret
+type Action string
+
+const (
+	// ActionMask replaces the secret with a fixed marker
+	ActionMask Action = "mask"
+	// ActionHash replaces the secret with a keyed hash, so equal values can
+	// still be correlated without being recoverable
+	ActionHash Action = "hash"
+	// ActionDrop removes the secret, or the whole field for field-name rules
+	ActionDrop Action = "drop"
+)
+
+// Mask is the marker written by ActionMask
+const Mask = "[REDACTED]"
+
+// DefaultFieldPattern matches field names whose values are always redacted
+var DefaultFieldPattern = regexp.MustCompile(`(?i)passw(or)?d|passwd|pwd|secret|token|api[_-]?key|authorization|cookie|credential`)
+
+// minFieldValueLen is the shortest field value also scrubbed from Raw and
+// Message; shorter values would match unrelated text
+const minFieldValueLen = 4
+
+// detector finds one kind of secret in free text. When the pattern has a
+// group named "secret", only that part of the match is redacted.
+type detector struct {
+	name  string
+	re    *regexp.Regexp
+	valid func(string) bool
+	count atomic.Uint64
+}
+
+// builtinDetectors returns fresh copies of the built-in detectors
+func builtinDetectors() []*detector {
+	return []*detector{
+		{name: "private_key", re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`)},
+		{name: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]+`)},
+		{name: "bearer", re: regexp.MustCompile(`(?:\b(?:Bearer|BEARER)\s+|(?i:\bauthorization)["']?\s*[=:]\s*["']?(?:[A-Za-z]+\s+)?)(?P<secret>[A-Za-z0-9\-._~+/]{8,}=*)`)},
+		{name: "url_password", re: regexp.MustCompile(`\b[A-Za-z][A-Za-z0-9+.-]*://[^:/\s@]*:(?P<secret>[^@\s/]+)@`)},
+		{name: "aws_access_key", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{name: "aws_secret_key", re: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[=:]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})`)},
+		{name: "password", re: regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret|api_?key|access_?token)["']?\s*[=:]\s*["']?(?P<secret>[^\s"'&,;\[][^\s"'&,;]*)`)},
+		{name: "email", re: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+		{name: "credit_card", re: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), valid: luhn},
+	}
+}
+
+// scrubs collects values removed by field-name rules, to be redacted from
+// the rest of the entry as well
+type scrubs struct {
+	values []string    // replaced wherever they appear
+	keyed  []*detector // numbers, only replaced after their field name
+}
+
+// Redactor scrubs secrets and personal data from entries before they are
+// stored. It is safe for concurrent use.
+type Redactor struct {
+	action       Action
+	detectors    []*detector
+	fieldPattern *regexp.Regexp
+	fieldCount   atomic.Uint64
+	key          []byte // HMAC key for ActionHash, random per process
+}
+
+// Option configures a Redactor
+type Option func(*Redactor)
+
+// WithAction sets what happens to detected secrets (default ActionMask)
+func WithAction(action Action) Option {
+	return func(r *Redactor) {
+		r.action = action
+	}
+}
+
+// WithPattern adds a detector; a group named "secret" limits redaction to
+// that part of each match
+func WithPattern(name string, re *regexp.Regexp) Option {
+	return func(r *Redactor) {
+		r.detectors = append(r.detectors, &detector{name: name, re: re})
+	}
+}
+
+// WithFieldPattern replaces DefaultFieldPattern; nil disables field-name rules
+func WithFieldPattern(re *regexp.Regexp) Option {
+	return func(r *Redactor) {
+		r.fieldPattern = re
+	}
+}
+
+// WithoutBuiltins disables the built-in detectors, keeping only patterns
+// added with WithPattern
+func WithoutBuiltins() Option {
+	return func(r *Redactor) {
+		r.detectors = nil
+	}
+}
+
+// New creates a redactor with the built-in detectors
+func New(opts ...Option) *Redactor {
+	r := &Redactor{
+		action:       ActionMask,
+		detectors:    builtinDetectors(),
+		fieldPattern: DefaultFieldPattern,
+		key:          make([]byte, 32),
+	}
+	rand.Read(r.key)
+
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// ParseAction validates an action name
+func ParseAction(s string) (Action, error) {
+	switch a := Action(s); a {
+	case ActionMask, ActionHash, ActionDrop:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown redaction action %q (mask, hash or drop)", s)
+}
+
+// Redact scrubs Raw, the parsed message, source, trace and request IDs and
+// fields of entry in place. The ANSI style spans of Raw move along with the
+// text they cover.
+func (r *Redactor) Redact(entry *models.LogEntry) {
+	parsed := entry.Parsed
+	if parsed != nil && parsed.Fields != nil {
+		var sc scrubs
+		r.redactFields(parsed.Fields, &sc)
+		// The same values usually appear in the raw line as well
+		for _, value := range sc.values {
+			var edits []ansi.Edit
+			entry.Raw = r.replaceAll(entry.Raw, value, &edits)
+			entry.Styles = ansi.Shift(entry.Styles, edits)
+			parsed.Message = r.replaceAll(parsed.Message, value, nil)
+		}
+		for _, d := range sc.keyed {
+			var edits []ansi.Edit
+			entry.Raw = r.applyDetector(d, entry.Raw, &edits)
+			entry.Styles = ansi.Shift(entry.Styles, edits)
+			parsed.Message = r.applyDetector(d, parsed.Message, nil)
+		}
+		for _, s := range []*string{&parsed.Source, &parsed.TraceID, &parsed.RequestID} {
+			for _, value := range sc.values {
+				*s = r.replaceAll(*s, value, nil)
+			}
+		}
+	}
+
+	for _, d := range r.detectors {
+		var edits []ansi.Edit
+		entry.Raw = r.applyDetector(d, entry.Raw, &edits)
+		entry.Styles = ansi.Shift(entry.Styles, edits)
+	}
+	if parsed != nil {
+		parsed.Message = r.redactText(parsed.Message)
+		parsed.Source = r.redactText(parsed.Source)
+		parsed.TraceID = r.redactText(parsed.TraceID)
+		parsed.RequestID = r.redactText(parsed.RequestID)
+	}
+}
+
+// replaceAll replaces every occurrence of value in s, recording the edits
+// when edits is not nil
+func (r *Redactor) replaceAll(s, value string, edits *[]ansi.Edit) string {
+	if !strings.Contains(s, value) {
+		return s
+	}
+	repl := r.replacement(value)
+	var b strings.Builder
+	for off := 0; ; {
+		i := strings.Index(s[off:], value)
+		if i < 0 {
+			b.WriteString(s[off:])
+			break
+		}
+		start := off + i
+		if edits != nil {
+			*edits = append(*edits, ansi.Edit{Start: start, End: start + len(value), Len: len(repl)})
+		}
+		b.WriteString(s[off:start])
+		b.WriteString(repl)
+		off = start + len(value)
+	}
+	return b.String()
+}
+
+// redactFields applies field-name rules and detectors to a decoded record,
+// adding the values removed by field-name rules to sc
+func (r *Redactor) redactFields(fields map[string]any, sc *scrubs) {
+	for k, v := range fields {
+		if r.fieldPattern != nil && r.fieldPattern.MatchString(k) {
+			if s, ok := v.(string); ok && len(s) >= minFieldValueLen {
+				sc.values = append(sc.values, s)
+			} else if n, ok := formatNumber(v); ok {
+				sc.keyed = append(sc.keyed, keyedDetector(k, n))
+			}
+			r.fieldCount.Add(1)
+			if r.action == ActionDrop {
+				delete(fields, k)
+			} else {
+				fields[k] = r.replacement(fmt.Sprint(v))
+			}
+			continue
+		}
+		fields[k] = r.redactValue(v, sc)
+	}
+}
+
+func (r *Redactor) redactValue(v any, sc *scrubs) any {
+	switch tv := v.(type) {
+	case string:
+		return r.redactText(tv)
+	case map[string]any:
+		r.redactFields(tv, sc)
+	case []any:
+		for i, item := range tv {
+			tv[i] = r.redactValue(item, sc)
+		}
+	}
+	return v
+}
+
+// formatNumber returns a numeric field value as it is written in a line
+func formatNumber(v any) (string, bool) {
+	switch n := v.(type) {
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(n), true
+	case int64:
+		return strconv.FormatInt(n, 10), true
+	case uint64:
+		return strconv.FormatUint(n, 10), true
+	case json.Number:
+		return n.String(), true
+	}
+	return "", false
+}
+
+// keyedDetector matches number right after its field name, as in "pin": 1234
+// or pin=1234; numbers are too common to replace wherever they appear
+func keyedDetector(key, number string) *detector {
+	re := regexp.MustCompile(regexp.QuoteMeta(key) + `["']?\s*[=:]\s*["']?(?P<secret>` + regexp.QuoteMeta(number) + `)\b`)
+	return &detector{name: "field", re: re}
+}
+
+// redactText runs every detector over s
+func (r *Redactor) redactText(s string) string {
+	for _, d := range r.detectors {
+		s = r.applyDetector(d, s, nil)
+	}
+	return s
+}
+
+// applyDetector replaces the matches of d in s, recording the edits when
+// edits is not nil
+func (r *Redactor) applyDetector(d *detector, s string, edits *[]ansi.Edit) string {
+	matches := d.re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	secret := d.re.SubexpIndex("secret")
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if secret > 0 && m[2*secret] >= 0 {
+			start, end = m[2*secret], m[2*secret+1]
+		}
+		value := s[start:end]
+		if d.valid != nil && !d.valid(value) {
+			continue
+		}
+		d.count.Add(1)
+		repl := r.replacement(value)
+		if edits != nil {
+			*edits = append(*edits, ansi.Edit{Start: start, End: end, Len: len(repl)})
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(repl)
+		last = end
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// replacement returns what a secret is replaced with under the action
+func (r *Redactor) replacement(value string) string {
+	switch r.action {
+	case ActionDrop:
+		return ""
+	case ActionHash:
+		mac := hmac.New(sha256.New, r.key)
+		mac.Write([]byte(value))
+		return "[hash:" + hex.EncodeToString(mac.Sum(nil))[:12] + "]"
+	default:
+		return Mask
+	}
+}
+
+// Counts returns how many values each detector has redacted so far, with
+// field-name rules counted under "field"
+func (r *Redactor) Counts() map[string]uint64 {
+	counts := make(map[string]uint64, len(r.detectors)+1)
+	for _, d := range r.detectors {
+		counts[d.name] += d.count.Load()
+	}
+	if r.fieldPattern != nil {
+		counts["field"] = r.fieldCount.Load()
+	}
+	return counts
+}
+
+// luhn validates a card number candidate, ignoring separators
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/lch88/logbro/internal/models"
+	"github.com/lch88/logbro/internal/parser"
 )
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -17,13 +18,24 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	capacity, used, totalReceived := s.buffer.Stats()
+	bytes, maxBytes, evictions := s.buffer.MemoryStats()
 
 	resp := models.StatusResponse{
-		BufferSize:    capacity,
-		BufferUsed:    used,
-		TotalReceived: totalReceived,
-		Uptime:        s.Uptime().Round(time.Second).String(),
-		StdinOpen:     s.hub.IsStdinOpen(),
+		BufferSize:     capacity,
+		BufferUsed:     used,
+		BufferBytes:    bytes,
+		BufferMaxBytes: maxBytes,
+		Evictions:      evictions,
+		TotalReceived:  totalReceived,
+		Uptime:         s.Uptime().Round(time.Second).String(),
+		StdinOpen:      s.hub.IsStdinOpen(),
+		Command:        s.command,
+		ExitCode:       s.hub.ExitCode(),
+		Levels:         parser.Levels(),
+		Store:          s.buffer.StoreStats(),
+	}
+	if s.redactor != nil {
+		resp.Redactions = s.redactor.Counts()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -32,14 +44,24 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleGetLogs(w http.ResponseWriter, r *http.Request) {
 	filter := models.LogFilter{
-		Search: r.URL.Query().Get("search"),
-		Regex:  r.URL.Query().Get("regex") == "true",
+		Search:    r.URL.Query().Get("search"),
+		Query:     r.URL.Query().Get("q"),
+		MinLevel:  r.URL.Query().Get("minLevel"),
+		Regex:     r.URL.Query().Get("regex") == "true",
+		Since:     r.URL.Query().Get("since"),
+		Until:     r.URL.Query().Get("until"),
+		TimeField: r.URL.Query().Get("timeField"),
+		Order:     r.URL.Query().Get("order"),
 	}
 
 	if levels := r.URL.Query().Get("levels"); levels != "" {
 		filter.Levels = strings.Split(levels, ",")
 	}
 
+	if sources := r.URL.Query().Get("sources"); sources != "" {
+		filter.Sources = strings.Split(sources, ",")
+	}
+
 	if afterID := r.URL.Query().Get("afterId"); afterID != "" {
 		if id, err := strconv.ParseUint(afterID, 10, 64); err == nil {
 			filter.AfterID = id
@@ -52,7 +74,11 @@ func (s *Server) handleGetLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	resp := s.buffer.Query(filter)
+	resp, err := s.buffer.Query(filter)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
@@ -63,3 +89,9 @@ func (s *Server) handleClearLogs(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "cleared"})
 }
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/lch88/logbro/internal/input"
+	"github.com/lch88/logbro/internal/parser"
+)
+
+// maxIngestBodySize bounds a single decompressed push request (64MB)
+const maxIngestBodySize = 64 * 1024 * 1024
+
+// handleIngest accepts newline-delimited JSON or plain text, one log per line
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	body, err := requestBody(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer body.Close()
+
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		source = r.Header.Get("X-Logbro-Source")
+	}
+
+	accepted := 0
+	asm := input.NewAssembler(s.multiline, func(lines []string) {
+		entry := s.parser.ParseLinesFrom(source, lines)
+		if source != "" {
+			parser.SetSource(&entry, source)
+		}
+		s.Ingest(entry)
+		accepted++
+	})
+	err = input.ReadLines(body, func(line string) {
+		if strings.TrimSpace(line) == "" {
+			return
+		}
+		asm.Add(strings.TrimSuffix(line, "\r"))
+	})
+	asm.Flush()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"accepted": accepted})
+}
+
+// requestBody returns the request body, transparently decompressing gzip
+func requestBody(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	if !strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		return http.MaxBytesReader(w, r.Body, maxIngestBodySize), nil
+	}
+
+	zr, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return http.MaxBytesReader(w, zr, maxIngestBodySize), nil
+}
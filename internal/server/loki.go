@@ -0,0 +1,279 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lch88/logbro/internal/codec"
+	"github.com/lch88/logbro/internal/models"
+	"github.com/lch88/logbro/internal/parser"
+)
+
+// DefaultLokiSourceLabels are the stream labels checked, in order, for an
+// entry's source when none are configured
+var DefaultLokiSourceLabels = []string{"app", "service", "service_name"}
+
+// WithLokiSourceLabels sets the stream labels used as an entry's source
+func WithLokiSourceLabels(labels []string) Option {
+	return func(s *Server) {
+		s.lokiSourceLabels = labels
+	}
+}
+
+// lokiEntry is one log line of a Loki push request, decoded from either
+// JSON or protobuf
+type lokiEntry struct {
+	time     time.Time
+	line     string
+	metadata map[string]string
+}
+
+// lokiStream is a set of entries sharing the same labels
+type lokiStream struct {
+	labels  map[string]string
+	entries []lokiEntry
+}
+
+// handleLokiPush implements POST /loki/api/v1/push
+func (s *Server) handleLokiPush(w http.ResponseWriter, r *http.Request) {
+	body, err := requestBody(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var streams []lokiStream
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType == "application/json" {
+		streams, err = decodeLokiJSON(data)
+	} else {
+		// Promtail and the Docker driver send snappy-compressed protobuf
+		streams, err = decodeLokiProto(data)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for _, stream := range streams {
+		source := s.lokiSource(stream.labels)
+		for _, e := range stream.entries {
+			entry := s.parser.ParseFrom(source, e.line)
+			if entry.Parsed == nil {
+				entry.Parsed = &models.ParsedLog{}
+			}
+			if entry.Parsed.Time == nil && !e.time.IsZero() {
+				t := e.time
+				entry.Parsed.Time = &t
+			}
+			if entry.Parsed.Fields == nil {
+				entry.Parsed.Fields = make(map[string]any)
+			}
+			for _, labels := range []map[string]string{stream.labels, e.metadata} {
+				for k, v := range labels {
+					if _, exists := entry.Parsed.Fields[k]; !exists {
+						entry.Parsed.Fields[k] = v
+					}
+				}
+			}
+			if source != "" {
+				parser.SetSource(&entry, source)
+			}
+			s.Ingest(entry)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) lokiSource(labels map[string]string) string {
+	for _, name := range s.lokiSourceLabels {
+		if v := labels[name]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// decodeLokiJSON decodes the JSON push format:
+// {"streams":[{"stream":{"app":"x"},"values":[["<unix ns>","line",{"meta":"v"}]]}]}
+func decodeLokiJSON(data []byte) ([]lokiStream, error) {
+	var req struct {
+		Streams []struct {
+			Stream map[string]string   `json:"stream"`
+			Values [][]json.RawMessage `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid push request: %w", err)
+	}
+
+	streams := make([]lokiStream, 0, len(req.Streams))
+	for _, rs := range req.Streams {
+		stream := lokiStream{labels: rs.Stream}
+		for _, value := range rs.Values {
+			if len(value) < 2 {
+				return nil, fmt.Errorf("invalid push request: value needs timestamp and line")
+			}
+			var ts, line string
+			if err := json.Unmarshal(value[0], &ts); err != nil {
+				return nil, fmt.Errorf("invalid timestamp: %w", err)
+			}
+			if err := json.Unmarshal(value[1], &line); err != nil {
+				return nil, fmt.Errorf("invalid line: %w", err)
+			}
+			ns, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q", ts)
+			}
+
+			e := lokiEntry{time: time.Unix(0, ns), line: line}
+			if len(value) > 2 {
+				// Structured metadata is optional and ignored if malformed
+				json.Unmarshal(value[2], &e.metadata)
+			}
+			stream.entries = append(stream.entries, e)
+		}
+		streams = append(streams, stream)
+	}
+	return streams, nil
+}
+
+// decodeLokiProto decodes a snappy-compressed logproto.PushRequest
+func decodeLokiProto(data []byte) ([]lokiStream, error) {
+	raw, err := codec.SnappyDecode(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snappy body: %w", err)
+	}
+
+	var streams []lokiStream
+	err = codec.EachProtoField(raw, func(f codec.ProtoField) error {
+		if f.Num != 1 || f.Type != codec.WireBytes {
+			return nil
+		}
+		stream, err := decodeLokiProtoStream(f.Bytes)
+		if err != nil {
+			return err
+		}
+		streams = append(streams, stream)
+		return nil
+	})
+	return streams, err
+}
+
+// decodeLokiProtoStream decodes a StreamAdapter{labels=1, entries=2}
+func decodeLokiProtoStream(b []byte) (lokiStream, error) {
+	var stream lokiStream
+	err := codec.EachProtoField(b, func(f codec.ProtoField) error {
+		switch {
+		case f.Num == 1 && f.Type == codec.WireBytes:
+			labels, err := parseLokiLabels(f.String())
+			if err != nil {
+				return err
+			}
+			stream.labels = labels
+		case f.Num == 2 && f.Type == codec.WireBytes:
+			e, err := decodeLokiProtoEntry(f.Bytes)
+			if err != nil {
+				return err
+			}
+			stream.entries = append(stream.entries, e)
+		}
+		return nil
+	})
+	return stream, err
+}
+
+// decodeLokiProtoEntry decodes an EntryAdapter{timestamp=1, line=2, structuredMetadata=3}
+func decodeLokiProtoEntry(b []byte) (lokiEntry, error) {
+	var e lokiEntry
+	err := codec.EachProtoField(b, func(f codec.ProtoField) error {
+		if f.Type != codec.WireBytes {
+			return nil
+		}
+		switch f.Num {
+		case 1:
+			var sec, nsec int64
+			err := codec.EachProtoField(f.Bytes, func(tf codec.ProtoField) error {
+				switch tf.Num {
+				case 1:
+					sec = tf.Int()
+				case 2:
+					nsec = tf.Int()
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			e.time = time.Unix(sec, nsec)
+		case 2:
+			e.line = f.String()
+		case 3:
+			var name, value string
+			err := codec.EachProtoField(f.Bytes, func(lf codec.ProtoField) error {
+				switch lf.Num {
+				case 1:
+					name = lf.String()
+				case 2:
+					value = lf.String()
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if e.metadata == nil {
+				e.metadata = make(map[string]string)
+			}
+			e.metadata[name] = value
+		}
+		return nil
+	})
+	return e, err
+}
+
+// parseLokiLabels parses a Prometheus label set such as {app="api", env="dev"}
+func parseLokiLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("invalid labels %q", s)
+	}
+	s = s[1 : len(s)-1]
+
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if s == "" {
+			return labels, nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid labels: missing '=' in %q", s)
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " ")
+
+		value, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label value for %q: %w", name, err)
+		}
+		s = s[len(value):]
+		if labels[name], err = strconv.Unquote(value); err != nil {
+			return nil, fmt.Errorf("invalid label value for %q: %w", name, err)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lch88/logbro/internal/codec"
+	"github.com/lch88/logbro/internal/models"
+	"github.com/lch88/logbro/internal/parser"
+)
+
+// maxOTLPDepth bounds the nesting of array and kvlist values
+const maxOTLPDepth = 100
+
+// otlpRecord is a LogRecord flattened together with its resource and scope,
+// decoded from either protobuf or JSON
+type otlpRecord struct {
+	time           time.Time
+	severityNumber int
+	severityText   string
+	body           any
+	attributes     map[string]any
+	traceID        string
+	spanID         string
+	resource       map[string]any
+	scope          string
+}
+
+// handleOTLPLogs implements the OTLP/HTTP logs endpoint (POST /v1/logs)
+func (s *Server) handleOTLPLogs(w http.ResponseWriter, r *http.Request) {
+	body, err := requestBody(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	isJSON := contentType == "application/json"
+
+	var records []otlpRecord
+	if isJSON {
+		records, err = decodeOTLPJSON(data)
+	} else {
+		records, err = decodeOTLPProto(data)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for _, rec := range records {
+		s.Ingest(rec.toEntry())
+	}
+
+	// An empty ExportLogsServiceResponse signals full success
+	if isJSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (rec otlpRecord) toEntry() models.LogEntry {
+	parsed := &models.ParsedLog{
+		Level:   parser.OTelSeverityLevel(rec.severityNumber),
+		TraceID: rec.traceID,
+		SpanID:  rec.spanID,
+		Fields:  make(map[string]any),
+	}
+	if parsed.Level == "" && rec.severityText != "" {
+		parsed.Level = parser.NormalizeLevel(rec.severityText)
+	}
+	if !rec.time.IsZero() {
+		t := rec.time
+		parsed.Time = &t
+	}
+
+	switch b := rec.body.(type) {
+	case string:
+		parsed.Message = b
+	case nil:
+	default:
+		data, _ := json.Marshal(b)
+		parsed.Message = string(data)
+		parsed.Fields["body"] = b
+	}
+
+	for k, v := range rec.attributes {
+		parsed.Fields[k] = v
+	}
+	parser.ExtractTrace(parsed)
+	if name, ok := rec.resource["service.name"].(string); ok {
+		parsed.Source = name
+		delete(rec.resource, "service.name")
+	}
+	if len(rec.resource) > 0 {
+		parsed.Fields["resource"] = rec.resource
+	}
+	if rec.scope != "" {
+		parsed.Fields["scope"] = rec.scope
+	}
+	if len(parsed.Fields) == 0 {
+		parsed.Fields = nil
+	}
+
+	raw := parsed.Message
+	if raw == "" && len(rec.attributes) > 0 {
+		// Records without a body still show their attributes
+		data, _ := json.Marshal(rec.attributes)
+		raw = string(data)
+	}
+	return models.LogEntry{
+		Timestamp: time.Now(),
+		Raw:       raw,
+		Parsed:    parsed,
+	}
+}
+
+// decodeOTLPProto decodes an ExportLogsServiceRequest
+func decodeOTLPProto(data []byte) ([]otlpRecord, error) {
+	var records []otlpRecord
+
+	// ExportLogsServiceRequest{resource_logs=1}
+	err := codec.EachProtoField(data, func(f codec.ProtoField) error {
+		if f.Num != 1 || f.Type != codec.WireBytes {
+			return nil
+		}
+
+		// ResourceLogs{resource=1, scope_logs=2}
+		var resource map[string]any
+		var scopeLogs [][]byte
+		err := codec.EachProtoField(f.Bytes, func(rf codec.ProtoField) error {
+			switch {
+			case rf.Num == 1 && rf.Type == codec.WireBytes:
+				// Resource{attributes=1}
+				var err error
+				resource, err = decodeProtoAttributes(rf.Bytes, 1, 0)
+				return err
+			case rf.Num == 2 && rf.Type == codec.WireBytes:
+				scopeLogs = append(scopeLogs, rf.Bytes)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, sl := range scopeLogs {
+			// ScopeLogs{scope=1, log_records=2}
+			var scope string
+			err := codec.EachProtoField(sl, func(sf codec.ProtoField) error {
+				if sf.Type != codec.WireBytes {
+					return nil
+				}
+				switch sf.Num {
+				case 1:
+					// InstrumentationScope{name=1}
+					return codec.EachProtoField(sf.Bytes, func(nf codec.ProtoField) error {
+						if nf.Num == 1 {
+							scope = nf.String()
+						}
+						return nil
+					})
+				case 2:
+					rec, err := decodeProtoLogRecord(sf.Bytes)
+					if err != nil {
+						return err
+					}
+					rec.resource = copyAttributes(resource)
+					rec.scope = scope
+					records = append(records, rec)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP request: %w", err)
+	}
+	return records, nil
+}
+
+// decodeProtoLogRecord decodes a LogRecord
+func decodeProtoLogRecord(b []byte) (otlpRecord, error) {
+	var rec otlpRecord
+	var observed uint64
+	attrs := make(map[string]any)
+
+	err := codec.EachProtoField(b, func(f codec.ProtoField) error {
+		switch f.Num {
+		case 1:
+			if f.Uint > 0 {
+				rec.time = time.Unix(0, int64(f.Uint))
+			}
+		case 2:
+			rec.severityNumber = int(f.Uint)
+		case 3:
+			rec.severityText = f.String()
+		case 5:
+			v, err := decodeProtoAnyValue(f.Bytes, 0)
+			if err != nil {
+				return err
+			}
+			rec.body = v
+		case 6:
+			k, v, err := decodeProtoKeyValue(f.Bytes, 0)
+			if err != nil {
+				return err
+			}
+			attrs[k] = v
+		case 9:
+			if len(f.Bytes) > 0 {
+				rec.traceID = hex.EncodeToString(f.Bytes)
+			}
+		case 10:
+			if len(f.Bytes) > 0 {
+				rec.spanID = hex.EncodeToString(f.Bytes)
+			}
+		case 11:
+			observed = f.Uint
+		}
+		return nil
+	})
+
+	if rec.time.IsZero() && observed > 0 {
+		rec.time = time.Unix(0, int64(observed))
+	}
+	rec.attributes = attrs
+	return rec, err
+}
+
+// decodeProtoAttributes collects the repeated KeyValue field num of a
+// message nested depth values deep
+func decodeProtoAttributes(b []byte, num, depth int) (map[string]any, error) {
+	attrs := make(map[string]any)
+	err := codec.EachProtoField(b, func(f codec.ProtoField) error {
+		if f.Num != num || f.Type != codec.WireBytes {
+			return nil
+		}
+		k, v, err := decodeProtoKeyValue(f.Bytes, depth)
+		if err != nil {
+			return err
+		}
+		attrs[k] = v
+		return nil
+	})
+	return attrs, err
+}
+
+// decodeProtoKeyValue decodes a KeyValue{key=1, value=2}
+func decodeProtoKeyValue(b []byte, depth int) (string, any, error) {
+	var key string
+	var value any
+	err := codec.EachProtoField(b, func(f codec.ProtoField) error {
+		switch f.Num {
+		case 1:
+			key = f.String()
+		case 2:
+			v, err := decodeProtoAnyValue(f.Bytes, depth)
+			if err != nil {
+				return err
+			}
+			value = v
+		}
+		return nil
+	})
+	return key, value, err
+}
+
+// decodeProtoAnyValue decodes an AnyValue oneof into a JSON-compatible
+// value. depth counts the arrays and lists it is nested in.
+func decodeProtoAnyValue(b []byte, depth int) (any, error) {
+	if depth > maxOTLPDepth {
+		return nil, fmt.Errorf("value nested deeper than %d levels", maxOTLPDepth)
+	}
+	var value any
+	err := codec.EachProtoField(b, func(f codec.ProtoField) error {
+		switch f.Num {
+		case 1: // string_value
+			value = f.String()
+		case 2: // bool_value
+			value = f.Uint != 0
+		case 3: // int_value
+			value = f.Int()
+		case 4: // double_value
+			value = f.Double()
+		case 5: // array_value: ArrayValue{values=1}
+			arr := []any{}
+			err := codec.EachProtoField(f.Bytes, func(af codec.ProtoField) error {
+				if af.Num != 1 {
+					return nil
+				}
+				v, err := decodeProtoAnyValue(af.Bytes, depth+1)
+				if err != nil {
+					return err
+				}
+				arr = append(arr, v)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			value = arr
+		case 6: // kvlist_value: KeyValueList{values=1}
+			kv, err := decodeProtoAttributes(f.Bytes, 1, depth+1)
+			if err != nil {
+				return err
+			}
+			value = kv
+		case 7: // bytes_value
+			value = hex.EncodeToString(f.Bytes)
+		}
+		return nil
+	})
+	return value, err
+}
+
+// OTLP/JSON encoding (https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding)
+type otlpJSONRequest struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []otlpJSONKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			LogRecords []struct {
+				TimeUnixNano         json.Number        `json:"timeUnixNano"`
+				ObservedTimeUnixNano json.Number        `json:"observedTimeUnixNano"`
+				SeverityNumber       int                `json:"severityNumber"`
+				SeverityText         string             `json:"severityText"`
+				Body                 *otlpJSONAnyValue  `json:"body"`
+				Attributes           []otlpJSONKeyValue `json:"attributes"`
+				TraceID              string             `json:"traceId"`
+				SpanID               string             `json:"spanId"`
+			} `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+type otlpJSONKeyValue struct {
+	Key   string           `json:"key"`
+	Value otlpJSONAnyValue `json:"value"`
+}
+
+type otlpJSONAnyValue struct {
+	StringValue *string            `json:"stringValue"`
+	BoolValue   *bool              `json:"boolValue"`
+	IntValue    *json.Number       `json:"intValue"`
+	DoubleValue *float64           `json:"doubleValue"`
+	BytesValue  *string            `json:"bytesValue"`
+	ArrayValue  *otlpJSONArray     `json:"arrayValue"`
+	KvlistValue *otlpJSONKeyValues `json:"kvlistValue"`
+}
+
+type otlpJSONArray struct {
+	Values []otlpJSONAnyValue `json:"values"`
+}
+
+type otlpJSONKeyValues struct {
+	Values []otlpJSONKeyValue `json:"values"`
+}
+
+func (v otlpJSONAnyValue) value() any {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		if i, err := v.IntValue.Int64(); err == nil {
+			return i
+		}
+		return v.IntValue.String()
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	case v.BytesValue != nil:
+		// Hex, as on the protobuf path
+		if b, err := base64.StdEncoding.DecodeString(*v.BytesValue); err == nil {
+			return hex.EncodeToString(b)
+		}
+		return *v.BytesValue
+	case v.ArrayValue != nil:
+		arr := make([]any, 0, len(v.ArrayValue.Values))
+		for _, item := range v.ArrayValue.Values {
+			arr = append(arr, item.value())
+		}
+		return arr
+	case v.KvlistValue != nil:
+		return jsonAttributes(v.KvlistValue.Values)
+	}
+	return nil
+}
+
+func jsonAttributes(kvs []otlpJSONKeyValue) map[string]any {
+	attrs := make(map[string]any, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.Key] = kv.Value.value()
+	}
+	return attrs
+}
+
+// decodeOTLPJSON decodes an ExportLogsServiceRequest in OTLP/JSON
+func decodeOTLPJSON(data []byte) ([]otlpRecord, error) {
+	var req otlpJSONRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("invalid OTLP request: %w", err)
+	}
+
+	var records []otlpRecord
+	for _, rl := range req.ResourceLogs {
+		resource := jsonAttributes(rl.Resource.Attributes)
+		for _, sl := range rl.ScopeLogs {
+			for _, lr := range sl.LogRecords {
+				rec := otlpRecord{
+					time:           unixNanoTime(lr.TimeUnixNano),
+					severityNumber: lr.SeverityNumber,
+					severityText:   lr.SeverityText,
+					attributes:     jsonAttributes(lr.Attributes),
+					traceID:        strings.ToLower(lr.TraceID),
+					spanID:         strings.ToLower(lr.SpanID),
+					resource:       copyAttributes(resource),
+					scope:          sl.Scope.Name,
+				}
+				if rec.time.IsZero() {
+					rec.time = unixNanoTime(lr.ObservedTimeUnixNano)
+				}
+				if lr.Body != nil {
+					rec.body = lr.Body.value()
+				}
+				records = append(records, rec)
+			}
+		}
+	}
+	return records, nil
+}
+
+func unixNanoTime(n json.Number) time.Time {
+	ns, err := strconv.ParseInt(n.String(), 10, 64)
+	if err != nil || ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// copyAttributes gives each record its own resource map so per-record edits
+// don't leak across records
+func copyAttributes(attrs map[string]any) map[string]any {
+	out := make(map[string]any, len(attrs))
+	for k, v := range attrs {
+		out[k] = v
+	}
+	return out
+}
@@ -10,6 +10,10 @@ import (
 	"time"
 
 	"github.com/lch88/logbro/internal/buffer"
+	"github.com/lch88/logbro/internal/input"
+	"github.com/lch88/logbro/internal/models"
+	"github.com/lch88/logbro/internal/parser"
+	"github.com/lch88/logbro/internal/redact"
 )
 
 //go:embed static/*
@@ -19,10 +23,16 @@ var staticFiles embed.FS
 type Server struct {
 	httpServer *http.Server
 	buffer     *buffer.Ring
+	parser     *parser.Parser
 	hub        *Hub
 	startTime  time.Time
 	port       int
 	devMode    bool
+	command    string
+	multiline  input.MultilineConfig
+	redactor   *redact.Redactor
+
+	lokiSourceLabels []string
 }
 
 // Option configures a Server
@@ -35,13 +45,44 @@ func WithDevMode() Option {
 	}
 }
 
+// WithCommand records the command line logbro is wrapping, reported in /api/status
+func WithCommand(command string) Option {
+	return func(s *Server) {
+		s.command = command
+	}
+}
+
+// WithParser sets the parser used for logs pushed over HTTP
+func WithParser(p *parser.Parser) Option {
+	return func(s *Server) {
+		s.parser = p
+	}
+}
+
+// WithMultiline sets how lines pushed to /api/ingest are grouped into events
+func WithMultiline(cfg input.MultilineConfig) Option {
+	return func(s *Server) {
+		s.multiline = cfg
+	}
+}
+
+// WithRedactor scrubs secrets from every entry before it is stored
+func WithRedactor(r *redact.Redactor) Option {
+	return func(s *Server) {
+		s.redactor = r
+	}
+}
+
 // New creates a new server instance
 func New(buf *buffer.Ring, port int, opts ...Option) *Server {
 	s := &Server{
 		buffer:    buf,
+		parser:    parser.New(),
 		hub:       NewHub(),
 		startTime: time.Now(),
 		port:      port,
+
+		lokiSourceLabels: DefaultLokiSourceLabels,
 	}
 
 	for _, opt := range opts {
@@ -55,6 +96,12 @@ func New(buf *buffer.Ring, port int, opts ...Option) *Server {
 	mux.HandleFunc("GET /api/status", s.handleStatus)
 	mux.HandleFunc("GET /api/logs", s.handleGetLogs)
 	mux.HandleFunc("DELETE /api/logs", s.handleClearLogs)
+	mux.HandleFunc("GET /api/traces/{id}", s.handleGetTrace)
+	mux.HandleFunc("POST /api/ingest", s.handleIngest)
+
+	// Push-compatible receivers
+	mux.HandleFunc("POST /loki/api/v1/push", s.handleLokiPush)
+	mux.HandleFunc("POST /v1/logs", s.handleOTLPLogs)
 
 	// WebSocket
 	mux.HandleFunc("GET /ws/logs", s.handleWebSocket)
@@ -120,6 +167,15 @@ func (s *Server) Hub() *Hub {
 	return s.hub
 }
 
+// Ingest stores an entry in the buffer and broadcasts it to WebSocket clients
+func (s *Server) Ingest(entry models.LogEntry) {
+	if s.redactor != nil {
+		s.redactor.Redact(&entry)
+	}
+	entry = s.buffer.Add(entry)
+	s.hub.Broadcast(entry)
+}
+
 // Port returns the server port
 func (s *Server) Port() int {
 	return s.port
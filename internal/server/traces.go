@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/lch88/logbro/internal/models"
+	"github.com/lch88/logbro/internal/query"
+)
+
+// handleGetTrace implements GET /api/traces/{id}, returning every buffered
+// entry with that trace or request id
+func (s *Server) handleGetTrace(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	logs := s.buffer.Trace(id)
+	if len(logs) == 0 {
+		// Hex trace ids are stored lower-cased
+		logs = s.buffer.Trace(strings.ToLower(id))
+	}
+	if len(logs) == 0 {
+		writeError(w, http.StatusNotFound, "no logs for trace "+id)
+		return
+	}
+
+	sort.SliceStable(logs, func(i, j int) bool {
+		return query.LogTime(logs[i]).Before(query.LogTime(logs[j]))
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TraceResponse{
+		ID:    id,
+		Logs:  logs,
+		Spans: spanTree(logs),
+	})
+}
+
+// spanTree groups logs by span and nests spans under their parents, in
+// order of each span's first entry. Spans whose parent logged nothing are
+// returned as roots.
+func spanTree(logs []models.LogEntry) []*models.TraceSpan {
+	spans := make(map[string]*models.TraceSpan)
+	var order []*models.TraceSpan
+	for _, entry := range logs {
+		p := entry.Parsed
+		if p == nil || p.SpanID == "" {
+			continue
+		}
+		span, ok := spans[p.SpanID]
+		if !ok {
+			span = &models.TraceSpan{SpanID: p.SpanID}
+			spans[p.SpanID] = span
+			order = append(order, span)
+		}
+		if span.ParentSpanID == "" {
+			span.ParentSpanID = p.ParentSpanID
+		}
+		if p.Source != "" && !slices.Contains(span.Sources, p.Source) {
+			span.Sources = append(span.Sources, p.Source)
+		}
+		span.LogIDs = append(span.LogIDs, entry.ID)
+	}
+
+	roots := []*models.TraceSpan{}
+	for _, span := range order {
+		parent, ok := spans[span.ParentSpanID]
+		if ok && !descendsFrom(parent, span, spans) {
+			parent.Children = append(parent.Children, span)
+		} else {
+			roots = append(roots, span)
+		}
+	}
+	return roots
+}
+
+// descendsFrom reports whether span is ancestor or has it among its
+// parents, in which case nesting ancestor under span would form a cycle
+func descendsFrom(span, ancestor *models.TraceSpan, spans map[string]*models.TraceSpan) bool {
+	for range len(spans) {
+		if span == nil {
+			return false
+		}
+		if span == ancestor {
+			return true
+		}
+		span = spans[span.ParentSpanID]
+	}
+	return true
+}
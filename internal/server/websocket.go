@@ -4,12 +4,15 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/lch88/logbro/internal/models"
+	"github.com/lch88/logbro/internal/parser"
+	"github.com/lch88/logbro/internal/query"
 )
 
 var upgrader = websocket.Upgrader{
@@ -24,6 +27,7 @@ type Client struct {
 	conn   *websocket.Conn
 	send   chan []byte
 	filter models.LogFilter
+	expr   query.Expr // filter.Query and time range
 	mu     sync.Mutex
 }
 
@@ -35,6 +39,7 @@ type Hub struct {
 	unregister chan *Client
 	mu         sync.RWMutex
 	stdinOpen  bool
+	exitCode   *int
 }
 
 // NewHub creates a new Hub instance
@@ -69,10 +74,10 @@ func (h *Hub) Run() {
 			h.mu.RLock()
 			for client := range h.clients {
 				client.mu.Lock()
-				filter := client.filter
+				filter, expr := client.filter, client.expr
 				client.mu.Unlock()
 
-				if h.matchesFilter(entry, filter) {
+				if h.matchesFilter(entry, filter, expr) {
 					msg := models.WSMessage{Type: "log", Data: entry}
 					data, _ := json.Marshal(msg)
 					select {
@@ -102,33 +107,60 @@ func (h *Hub) SetStdinClosed() {
 	h.stdinOpen = false
 	h.mu.Unlock()
 
-	// Notify all clients
+	h.notifyStatus()
+}
+
+// SetExited records the wrapped command's exit code, marks input as closed
+// and notifies all clients
+func (h *Hub) SetExited(code int) {
+	h.mu.Lock()
+	h.stdinOpen = false
+	h.exitCode = &code
+	h.mu.Unlock()
+
+	h.notifyStatus()
+}
+
+// IsStdinOpen returns whether stdin is still open
+func (h *Hub) IsStdinOpen() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.stdinOpen
+}
+
+// ExitCode returns the wrapped command's exit code, or nil while it is running
+func (h *Hub) ExitCode() *int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.exitCode
+}
+
+// notifyStatus sends the current input status to all clients
+func (h *Hub) notifyStatus() {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	status := map[string]any{"stdinOpen": h.stdinOpen}
+	if h.exitCode != nil {
+		status["exitCode"] = *h.exitCode
+	}
 	msg := models.WSMessage{
 		Type: "status",
-		Data: map[string]bool{"stdinOpen": false},
+		Data: status,
 	}
 	data, _ := json.Marshal(msg)
 
-	h.mu.RLock()
 	for client := range h.clients {
 		select {
 		case client.send <- data:
 		default:
 		}
 	}
-	h.mu.RUnlock()
 }
 
-// IsStdinOpen returns whether stdin is still open
-func (h *Hub) IsStdinOpen() bool {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-	return h.stdinOpen
-}
-
-func (h *Hub) matchesFilter(entry models.LogEntry, filter models.LogFilter) bool {
+func (h *Hub) matchesFilter(entry models.LogEntry, filter models.LogFilter, expr query.Expr) bool {
 	// If no filter, match all
-	if len(filter.Levels) == 0 && filter.Search == "" {
+	if len(filter.Levels) == 0 && filter.MinLevel == "" && len(filter.Sources) == 0 && filter.Search == "" && expr == nil {
 		return true
 	}
 
@@ -149,6 +181,18 @@ func (h *Hub) matchesFilter(entry models.LogEntry, filter models.LogFilter) bool
 		}
 	}
 
+	if filter.MinLevel != "" {
+		if entry.Parsed == nil || !parser.LevelAtLeast(entry.Parsed.Level, filter.MinLevel) {
+			return false
+		}
+	}
+
+	if len(filter.Sources) > 0 {
+		if entry.Parsed == nil || !slices.Contains(filter.Sources, entry.Parsed.Source) {
+			return false
+		}
+	}
+
 	// Check search filter (simple case-insensitive contains)
 	if filter.Search != "" {
 		if !strings.Contains(strings.ToLower(entry.Raw), strings.ToLower(filter.Search)) {
@@ -156,7 +200,7 @@ func (h *Hub) matchesFilter(entry models.LogEntry, filter models.LogFilter) bool
 		}
 	}
 
-	return true
+	return expr == nil || expr.Match(&entry)
 }
 
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
@@ -207,12 +251,19 @@ func (c *Client) readPump() {
 
 		switch msg.Type {
 		case "subscribe":
+			// Relative time bounds are fixed when subscribing
+			expr, err := query.Compile(msg.Filter, time.Now())
+			if err != nil {
+				// Keep the previous subscription
+				c.sendError(err.Error())
+				continue
+			}
 			c.mu.Lock()
-			c.filter = msg.Filter
+			c.filter, c.expr = msg.Filter, expr
 			c.mu.Unlock()
 		case "unsubscribe":
 			c.mu.Lock()
-			c.filter = models.LogFilter{}
+			c.filter, c.expr = models.LogFilter{}, nil
 			c.mu.Unlock()
 		case "ping":
 			pong := models.WSMessage{Type: "pong"}
@@ -225,6 +276,15 @@ func (c *Client) readPump() {
 	}
 }
 
+// sendError tells the client a message it sent was rejected
+func (c *Client) sendError(msg string) {
+	data, _ := json.Marshal(models.WSMessage{Type: "error", Data: map[string]string{"error": msg}})
+	select {
+	case c.send <- data:
+	default:
+	}
+}
+
 func (c *Client) writePump() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer func() {
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lch88/logbro/internal/models"
+	"github.com/lch88/logbro/internal/query"
+)
+
+// File name suffixes of a segment's data and its sparse index
+const (
+	dataSuffix  = ".seg"
+	indexSuffix = ".idx"
+)
+
+// block describes one compressed run of entries inside a segment; the
+// index file holds one JSON line per block
+type block struct {
+	First   uint64    `json:"first"`   // lowest entry ID
+	Last    uint64    `json:"last"`    // highest entry ID
+	MinTime time.Time `json:"minTime"` // earliest log time
+	MaxTime time.Time `json:"maxTime"` // latest log time
+	Arrived time.Time `json:"arrived"` // when the newest entry was received
+	Offset  int64     `json:"offset"`
+	Size    int64     `json:"size"`
+}
+
+// overlaps reports whether the block may hold entries within tr
+func (b block) overlaps(tr models.TimeRange) bool {
+	if tr.LogTime {
+		return (tr.Since.IsZero() || !b.MaxTime.Before(tr.Since)) &&
+			(tr.Until.IsZero() || b.MinTime.Before(tr.Until))
+	}
+	// Only the newest arrival is recorded
+	return tr.Since.IsZero() || !b.Arrived.Before(tr.Since)
+}
+
+// segment is an append-only data file of gzip members, one per block
+type segment struct {
+	first  uint64 // ID the segment starts at, also its file name
+	path   string // data file, without the index suffix
+	blocks []block
+	size   int64
+}
+
+func segmentPath(dir string, first uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", first, dataSuffix))
+}
+
+// loadSegments opens the segments in dir, oldest first. Data written after
+// the last indexed block, from an interrupted write, is truncated.
+func loadSegments(dir string) ([]*segment, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+dataSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []*segment
+	for _, path := range paths {
+		first, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(path), dataSuffix), 10, 64)
+		if err != nil {
+			continue // not ours
+		}
+		seg := &segment{first: first, path: path}
+		if seg.blocks, err = readIndex(path + indexSuffix); err != nil {
+			return nil, fmt.Errorf("segment %s: %w", filepath.Base(path), err)
+		}
+		if n := len(seg.blocks); n > 0 {
+			seg.size = seg.blocks[n-1].Offset + seg.blocks[n-1].Size
+		}
+		if err := os.Truncate(path, seg.size); err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+	}
+	return segments, nil // Glob sorts, and the names are zero-padded IDs
+}
+
+// readIndex reads a segment index, ignoring a torn final line
+func readIndex(path string) ([]block, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var blocks []block
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var b block
+		if err := json.Unmarshal(scanner.Bytes(), &b); err != nil {
+			break
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, scanner.Err()
+}
+
+// encodeBlock compresses entries into one gzip member
+func encodeBlock(entries []models.LogEntry) ([]byte, block, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+
+	b := block{First: entries[0].ID, Last: entries[len(entries)-1].ID}
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return nil, block{}, err
+		}
+		t := query.LogTime(entry)
+		if b.MinTime.IsZero() || t.Before(b.MinTime) {
+			b.MinTime = t
+		}
+		if t.After(b.MaxTime) {
+			b.MaxTime = t
+		}
+		if entry.Timestamp.After(b.Arrived) {
+			b.Arrived = entry.Timestamp
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, block{}, err
+	}
+	b.Size = int64(buf.Len())
+	return buf.Bytes(), b, nil
+}
+
+// write stores an encoded block and its index line after the segment's
+// data, returning the block with its offset. The caller records it in
+// blocks and size.
+func (seg *segment) write(data []byte, b block) (block, error) {
+	b.Offset = seg.size
+
+	f, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return b, err
+	}
+	_, err = f.WriteAt(data, b.Offset)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return b, err
+	}
+
+	line, err := json.Marshal(b)
+	if err != nil {
+		return b, err
+	}
+	idx, err := os.OpenFile(seg.path+indexSuffix, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return b, err
+	}
+	_, err = idx.Write(append(line, '\n'))
+	if cerr := idx.Close(); err == nil {
+		err = cerr
+	}
+	return b, err
+}
+
+// remove deletes the segment's files
+func (seg *segment) remove() error {
+	err := os.Remove(seg.path)
+	if ierr := os.Remove(seg.path + indexSuffix); err == nil && !os.IsNotExist(ierr) {
+		err = ierr
+	}
+	return err
+}
+
+// readBlock decodes the entries of one block, calling fn until it returns
+// false
+func readBlock(path string, b block, fn func(models.LogEntry) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(io.NewSectionReader(f, b.Offset, b.Size))
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(gz)
+	for {
+		var entry models.LogEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !fn(entry) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Package store keeps log entries on disk beyond the in-memory ring buffer,
+// in append-only segments of gzip-compressed blocks with a sparse index of
+// each block's ID and time range
+package store
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lch88/logbro/internal/models"
+)
+
+// Defaults for segment layout
+const (
+	// DefaultMaxBytes bounds the disk space used by segments (1 GiB)
+	DefaultMaxBytes = 1 << 30
+	// maxBlockBytes is the uncompressed size at which a block is written
+	maxBlockBytes = 256 * 1024
+	// maxSegmentBytes is the compressed size at which a new segment starts
+	maxSegmentBytes = 16 * 1024 * 1024
+	// flushInterval bounds how long entries wait in the pending block
+	flushInterval = 5 * time.Second
+	// maxRetentionInterval bounds how often age retention is checked
+	maxRetentionInterval = time.Minute
+)
+
+// Store is a disk-backed log store. It is safe for concurrent use.
+// Appends only queue entries; blocks are compressed and written by Flush,
+// which runs in the background without holding the lock appends take.
+type Store struct {
+	mu       sync.Mutex
+	flushMu  sync.Mutex // serializes writers: Flush, retention and Clear
+	dir      string
+	maxBytes int64         // total segment size, 0 for unbounded
+	maxAge   time.Duration // age of a segment's newest entry, 0 for unbounded
+
+	segments     []*segment // oldest first, the last one takes appends
+	bytes        int64
+	lastID       uint64
+	pending      []models.LogEntry // entries not written yet
+	pendingBytes int
+	flushing     []models.LogEntry // entries being written by Flush
+	flushQueued  bool
+	timer        *time.Timer
+	done         chan struct{}
+}
+
+// Option configures a Store
+type Option func(*Store)
+
+// WithMaxBytes deletes the oldest segments once the store exceeds n bytes
+// on disk; 0 disables the limit
+func WithMaxBytes(n int64) Option {
+	return func(s *Store) {
+		s.maxBytes = n
+	}
+}
+
+// WithMaxAge deletes segments whose newest entry arrived more than d ago;
+// 0 disables the limit
+func WithMaxAge(d time.Duration) Option {
+	return func(s *Store) {
+		s.maxAge = d
+	}
+}
+
+// Open opens or creates a store in dir
+func Open(dir string, opts ...Option) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	segments, err := loadSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		dir:      dir,
+		maxBytes: DefaultMaxBytes,
+		segments: segments,
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for _, seg := range segments {
+		s.bytes += seg.size
+		if n := len(seg.blocks); n > 0 {
+			s.lastID = max(s.lastID, seg.blocks[n-1].Last)
+		}
+	}
+
+	if err := s.Retain(); err != nil {
+		return nil, err
+	}
+	if s.maxAge > 0 {
+		go s.retainLoop(min(s.maxAge/10, maxRetentionInterval))
+	}
+	return s, nil
+}
+
+// retainLoop applies age retention while no entries arrive to trigger it
+func (s *Store) retainLoop(interval time.Duration) {
+	ticker := time.NewTicker(max(interval, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Retain(); err != nil {
+				log.Printf("Store retention error: %v", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// LastID returns the highest entry ID stored, so a new buffer can continue
+// numbering after it
+func (s *Store) LastID() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastID
+}
+
+// Append queues an entry for writing; entries must arrive in ID order.
+// Queued entries are written in blocks by a background Flush and can be
+// scanned right away.
+func (s *Store) Append(entry models.LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry.ID <= s.lastID {
+		return nil // already stored, e.g. by a previous run
+	}
+	s.lastID = entry.ID
+	s.pending = append(s.pending, entry)
+	s.pendingBytes += len(entry.Raw) + 256
+
+	if s.pendingBytes >= maxBlockBytes && !s.flushQueued {
+		s.flushQueued = true
+		go s.backgroundFlush()
+	} else if s.timer == nil {
+		s.timer = time.AfterFunc(flushInterval, s.backgroundFlush)
+	}
+	return nil
+}
+
+func (s *Store) backgroundFlush() {
+	if err := s.Flush(); err != nil {
+		log.Printf("Store flush error: %v", err)
+	}
+}
+
+// Flush writes pending entries to disk
+func (s *Store) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.flushQueued = false
+	batch := s.pending
+	s.pending, s.pendingBytes = nil, 0
+	s.flushing = batch // still visible to Scan while being written
+	seg := s.activeSegment()
+	if len(batch) > 0 && (seg == nil || seg.size >= maxSegmentBytes) {
+		seg = &segment{first: batch[0].ID, path: segmentPath(s.dir, batch[0].ID)}
+		s.segments = append(s.segments, seg)
+	}
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// Only Flush changes segments' data, so seg can be written unlocked
+	data, b, err := encodeBlock(batch)
+	if err == nil {
+		b, err = seg.write(data, b)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushing = nil
+	if err != nil {
+		// Keep the entries for the next attempt
+		s.pending = append(batch, s.pending...)
+		for _, entry := range batch {
+			s.pendingBytes += len(entry.Raw) + 256
+		}
+		return fmt.Errorf("write segment: %w", err)
+	}
+	seg.blocks = append(seg.blocks, b)
+	seg.size += b.Size
+	s.bytes += b.Size
+	return s.enforceRetention()
+}
+
+// Retain deletes segments beyond the size and age limits. It runs after
+// every flush, and periodically when an age limit is set.
+func (s *Store) Retain() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enforceRetention()
+}
+
+// Clear deletes every segment and drops pending entries. IDs keep
+// increasing from the last stored one.
+func (s *Store) Clear() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending, s.pendingBytes = nil, 0
+	var err error
+	for _, seg := range s.segments {
+		if rerr := seg.remove(); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	s.segments = nil
+	s.bytes = 0
+	return err
+}
+
+func (s *Store) activeSegment() *segment {
+	if len(s.segments) == 0 {
+		return nil
+	}
+	return s.segments[len(s.segments)-1]
+}
+
+// enforceRetention deletes the oldest segments beyond the size and age
+// limits. The active segment is only deleted for its age, as the next
+// flush starts a new one. Callers hold flushMu and mu.
+func (s *Store) enforceRetention() error {
+	for len(s.segments) > 0 {
+		oldest := s.segments[0]
+		tooBig := s.maxBytes > 0 && s.bytes > s.maxBytes && len(s.segments) > 1
+		tooOld := s.maxAge > 0 && len(oldest.blocks) > 0 &&
+			time.Since(oldest.blocks[len(oldest.blocks)-1].Arrived) > s.maxAge
+		if !tooBig && !tooOld {
+			break
+		}
+		if err := oldest.remove(); err != nil {
+			return err
+		}
+		s.bytes -= oldest.size
+		s.segments = s.segments[1:]
+	}
+	return nil
+}
+
+// Scan calls fn for stored entries with afterID < ID < beforeID in ID
+// order, until fn returns false. Blocks outside the ID range, or entirely
+// outside tr, are skipped using the index without being read; entries of
+// the blocks read are passed on whatever their time.
+func (s *Store) Scan(afterID, beforeID uint64, tr models.TimeRange, fn func(models.LogEntry) bool) error {
+	type blockRef struct {
+		path string
+		b    block
+	}
+
+	s.mu.Lock()
+	var refs []blockRef
+	for _, seg := range s.segments {
+		for _, b := range seg.blocks {
+			if b.Last > afterID && b.First < beforeID && b.overlaps(tr) {
+				refs = append(refs, blockRef{seg.path, b})
+			}
+		}
+	}
+	pending := append(slices.Clone(s.flushing), s.pending...)
+	s.mu.Unlock()
+
+	more := true
+	visit := func(entry models.LogEntry) bool {
+		if entry.ID >= beforeID {
+			more = false
+		} else if entry.ID > afterID {
+			more = fn(entry)
+		}
+		return more
+	}
+
+	for _, ref := range refs {
+		if err := readBlock(ref.path, ref.b, visit); err != nil {
+			if os.IsNotExist(err) {
+				continue // removed by retention meanwhile
+			}
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	for _, entry := range pending {
+		if !visit(entry) {
+			break
+		}
+	}
+	return nil
+}
+
+// Stats describes the store for /api/status
+func (s *Store) Stats() models.StoreStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := models.StoreStats{
+		Dir:      s.dir,
+		Segments: len(s.segments),
+		Bytes:    s.bytes,
+		MaxBytes: s.maxBytes,
+		LastID:   s.lastID,
+	}
+	for _, seg := range s.segments {
+		if len(seg.blocks) > 0 {
+			stats.FirstID = seg.blocks[0].First
+			break
+		}
+	}
+	if stats.FirstID == 0 && len(s.flushing) > 0 {
+		stats.FirstID = s.flushing[0].ID
+	} else if stats.FirstID == 0 && len(s.pending) > 0 {
+		stats.FirstID = s.pending[0].ID
+	}
+	return stats
+}
+
+// Close stops age retention and writes pending entries
+func (s *Store) Close() error {
+	close(s.done)
+	return s.Flush()
+}